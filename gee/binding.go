@@ -0,0 +1,349 @@
+package gee
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// ParseMultipartForm 时保留在内存中的最大字节数，超出部分写入临时文件
+const defaultMultipartMemory = 32 << 20
+
+const (
+	MIMEJSON              = "application/json"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+)
+
+// 把请求中的数据解码到结构体中
+type binding func(req *http.Request, obj interface{}) error
+
+func bindJSON(req *http.Request, obj interface{}) error {
+	if req.Body == nil {
+		return errors.New("gee: empty request body")
+	}
+	if err := json.NewDecoder(req.Body).Decode(obj); err != nil {
+		if err == io.EOF {
+			return errors.New("gee: empty request body")
+		}
+		return err
+	}
+	return nil
+}
+
+func bindXML(req *http.Request, obj interface{}) error {
+	if req.Body == nil {
+		return errors.New("gee: empty request body")
+	}
+	if err := xml.NewDecoder(req.Body).Decode(obj); err != nil {
+		if err == io.EOF {
+			return errors.New("gee: empty request body")
+		}
+		return err
+	}
+	return nil
+}
+
+// url 参数和 x-www-form-urlencoded 表单都会参与绑定，表单优先
+func bindForm(req *http.Request, obj interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+	return mapForm(obj, req.Form, nil)
+}
+
+func bindMultipart(req *http.Request, obj interface{}) error {
+	if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		return err
+	}
+	return mapForm(obj, req.Form, req.MultipartForm.File)
+}
+
+func bindQuery(req *http.Request, obj interface{}) error {
+	return mapForm(obj, req.URL.Query(), nil)
+}
+
+/**
+ * 根据请求方式和 Content-Type 选择解码方式
+ * GET/HEAD/DELETE 等没有请求体的请求只绑定 url 参数
+ */
+func bindingFor(method string, contentType string) binding {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+		return bindQuery
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case MIMEJSON:
+		return bindJSON
+	case MIMEXML, MIMEXML2:
+		return bindXML
+	case MIMEMultipartPOSTForm:
+		return bindMultipart
+	default:
+		return bindForm
+	}
+}
+
+/**
+ * 把表单数据映射到结构体字段上
+ * 字段名取自 form tag，没有时使用字段名本身，tag 为 "-" 时忽略该字段
+ * 支持基本类型、time.Duration、time.Time（RFC3339）、以及它们的切片和指针，
+ * 匿名嵌入的结构体会被展开，multipart 请求还支持 *multipart.FileHeader
+ */
+func mapForm(obj interface{}, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("gee: binding target must be a non-nil pointer to struct")
+	}
+	return mapStruct(v.Elem(), values, files)
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	// 实现了 encoding.TextMarshaler 的类型在 OpenAPI 文档中是字符串
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func mapStruct(v reflect.Value, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := mapStruct(fv, values, files); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		switch field.Type {
+		case fileHeaderType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs[0]))
+			}
+			continue
+		case fileHeadersType:
+			if fhs := files[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok {
+			continue
+		}
+		if err := setField(fv, vs); err != nil {
+			return fmt.Errorf("gee: bind field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, vs []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Ptr:
+		if len(vs) == 0 {
+			return nil
+		}
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setValue(ptr.Elem(), vs[0]); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+	if len(vs) == 0 {
+		return nil
+	}
+	return setValue(fv, vs[0])
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == timeType {
+		if s == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// ShouldBind 根据请求方式和 Content-Type 选择解码方式，解码后执行校验
+// 出错时只返回 error，由调用者决定如何响应
+func (c *Context) ShouldBind(obj interface{}) error {
+	return c.shouldBindWith(obj, bindingFor(c.Method, c.Req.Header.Get("Content-Type")))
+}
+
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	return c.shouldBindWith(obj, bindJSON)
+}
+
+func (c *Context) ShouldBindXML(obj interface{}) error {
+	return c.shouldBindWith(obj, bindXML)
+}
+
+// 同时绑定 url 参数和表单，multipart 请求会绑定文件字段
+func (c *Context) ShouldBindForm(obj interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	if mediaType == MIMEMultipartPOSTForm {
+		return c.shouldBindWith(obj, bindMultipart)
+	}
+	return c.shouldBindWith(obj, bindForm)
+}
+
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	return c.shouldBindWith(obj, bindQuery)
+}
+
+func (c *Context) shouldBindWith(obj interface{}, b binding) error {
+	// multipart 表单先按 engine.Upload 的限制解析，bindMultipart 会直接使用解析结果
+	if isMultipart(c.Req) {
+		if _, err := c.MultipartForm(); err != nil {
+			return err
+		}
+	}
+	if err := b(c.Req, obj); err != nil {
+		return err
+	}
+	return Validate(obj)
+}
+
+// Bind 系列方法在出错时直接响应 400，并短路之后的中间件
+// 校验错误会以 errors 列表的形式返回给客户端
+func (c *Context) Bind(obj interface{}) error {
+	return c.bindOrFail(c.ShouldBind(obj))
+}
+
+func (c *Context) BindJSON(obj interface{}) error {
+	return c.bindOrFail(c.ShouldBindJSON(obj))
+}
+
+func (c *Context) BindXML(obj interface{}) error {
+	return c.bindOrFail(c.ShouldBindXML(obj))
+}
+
+func (c *Context) BindForm(obj interface{}) error {
+	return c.bindOrFail(c.ShouldBindForm(obj))
+}
+
+func (c *Context) BindQuery(obj interface{}) error {
+	return c.bindOrFail(c.ShouldBindQuery(obj))
+}
+
+func (c *Context) bindOrFail(err error) error {
+	if err == nil {
+		return nil
+	}
+	c.Abort() // 短路中间件的执行
+	body := H{"message": err.Error()}
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		body["message"] = "validation failed"
+		body["errors"] = verrs
+	}
+	// body 超出 http.MaxBytesReader 的限制
+	if isBodyTooLarge(err) {
+		c.JSON(http.StatusRequestEntityTooLarge, body)
+		return err
+	}
+	// 上传的文件超出 engine.Upload 的限制
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		body["message"] = httpErr.Detail
+		c.JSON(httpErr.Status, body)
+		return err
+	}
+	c.JSON(http.StatusBadRequest, body)
+	return err
+}
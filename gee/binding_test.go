@@ -0,0 +1,108 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city" form:"city" binding:"required"`
+}
+
+type testLogin struct {
+	User    string        `json:"user" form:"user" binding:"required,min=3,max=8"`
+	Role    string        `json:"role" form:"role" binding:"oneof=admin guest"`
+	Code    string        `json:"code" form:"code" binding:"len=4,regex=^[0-9]{2,4}$"`
+	Age     int           `json:"age" form:"age" binding:"min=18"`
+	Tags    []string      `json:"tags" form:"tag"`
+	Address *testAddress  `json:"address"`
+	Items   []testAddress `json:"items"`
+}
+
+func TestShouldBindJSON(t *testing.T) {
+	body := `{"user":"geektutu","role":"admin","code":"1234","age":20,"address":{"city":"hz"}}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	c := newContext(httptest.NewRecorder(), req)
+
+	var login testLogin
+	if err := c.ShouldBind(&login); err != nil {
+		t.Fatal(err)
+	}
+	if login.User != "geektutu" || login.Address.City != "hz" {
+		t.Fatalf("unexpected result %+v", login)
+	}
+}
+
+func TestShouldBindValidation(t *testing.T) {
+	body := `{"user":"ab","role":"root","code":"12a4","age":3,"items":[{"city":""}]}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	c := newContext(httptest.NewRecorder(), req)
+
+	var login testLogin
+	err := c.ShouldBind(&login)
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expect ValidationErrors, got %v", err)
+	}
+	var fields []string
+	for _, e := range verrs {
+		fields = append(fields, e.Field+":"+e.Rule)
+	}
+	expect := "user:min role:oneof code:regex age:min items[0].city:required"
+	if strings.Join(fields, " ") != expect {
+		t.Fatalf("expect %s, got %s", expect, strings.Join(fields, " "))
+	}
+}
+
+func TestShouldBindFormAndQuery(t *testing.T) {
+	form := url.Values{"user": {"geektutu"}, "role": {"guest"}, "code": {"1234"}, "age": {"18"}, "tag": {"a", "b"}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c := newContext(httptest.NewRecorder(), req)
+
+	var login testLogin
+	if err := c.ShouldBind(&login); err != nil {
+		t.Fatal(err)
+	}
+	if login.Age != 18 || len(login.Tags) != 2 || login.Tags[1] != "b" {
+		t.Fatalf("unexpected result %+v", login)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?"+form.Encode(), nil)
+	c = newContext(httptest.NewRecorder(), req)
+	login = testLogin{}
+	if err := c.ShouldBind(&login); err != nil || login.User != "geektutu" {
+		t.Fatalf("query binding failed: %v %+v", err, login)
+	}
+}
+
+func TestBindFail(t *testing.T) {
+	r := New()
+	r.POST("/login", func(c *Context) {
+		var login testLogin
+		if c.Bind(&login) != nil {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user":"ab"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expect 400, got %d", w.Code)
+	}
+	var resp struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Errors) == 0 {
+		t.Fatalf("expect structured errors, got %s", w.Body.String())
+	}
+}
@@ -3,7 +3,12 @@ package gee
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 // 方便构建 JSON 数据
@@ -18,7 +23,7 @@ type H map[string]interface{}
  * 可以找到任何东西。
  */
 type Context struct {
-	Writer http.ResponseWriter
+	Writer ResponseWriter
 	Req    *http.Request
 
 	// request 信息
@@ -32,22 +37,59 @@ type Context struct {
 
 	// middleware
 	handlers []HandlerFunc
-	index    int // 记录当前执行到第几个中间件
+	index    int // 记录当前执行到第几个中间件，被 Abort 后置为 abortIndex
 
-	engine *Engine
+	// 请求范围内的键值对，用于在中间件和 handler 之间传递数据，例如登录的用户
+	mu   sync.RWMutex
+	Keys map[string]interface{}
+	// 处理过程中通过 c.Error 收集的错误，中间件可以在 c.Next() 之后检查
+	Errors ErrorList
+
+	engine   *Engine
+	group    *RouterGroup // 匹配到的路由所在的分组，用于查找分组级别的配置（例如模板）
+	fullPath string       // 匹配到的路由 pattern，例如 /p/:lang，没有匹配时为 ""
+	logSink  *logSink     // 外层 LoggerWithConfig 的输出端，Recovery 通过它记录 panic
+	// c.Errors 是否已经交给 ErrorHandler 处理
+	errorsHandled bool
+	// 请求体是否已经按 engine.Upload.MaxTotalSize 限制
+	bodyLimited bool
+	// c.Req.MultipartForm 中的文件是否已经按 engine.Upload 检查过
+	formChecked bool
+	writermem   responseWriter // Writer 指向它，随 Context 一起复用
 }
 
 func newContext(w http.ResponseWriter, req *http.Request) *Context {
-	return &Context{
-		Writer: w,
-		Req:    req,
-		Path:   req.URL.Path, // 不包含参数信息
-		// 例如地址 /?name=123
-		// req.URL.Path = /
-		// req.RequestURI = /?name=123 注意区分
-		Method: req.Method,
-		index:  -1,
-	}
+	c := &Context{}
+	c.reset(w, req)
+	return c
+}
+
+/**
+ * Context 由 Engine 的 sync.Pool 复用，每次请求开始前重置所有字段
+ * 因此 handler 返回之后不能再持有 Context（例如在新的 goroutine 中使用），
+ * 需要的话应先拷贝出所需的数据
+ */
+func (c *Context) reset(w http.ResponseWriter, req *http.Request) {
+	c.writermem.reset(w)
+	c.Writer = &c.writermem
+	c.Req = req
+	c.Path = req.URL.Path // 不包含参数信息
+	// 例如地址 /?name=123
+	// req.URL.Path = /
+	// req.RequestURI = /?name=123 注意区分
+	c.Method = req.Method
+	c.Params = nil
+	c.StatusCode = 0
+	c.handlers = nil
+	c.index = -1
+	c.group = nil
+	c.fullPath = ""
+	c.logSink = nil
+	c.errorsHandled = false
+	c.bodyLimited = false
+	c.formChecked = false
+	c.Keys = nil
+	c.Errors = c.Errors[:0]
 }
 
 /**
@@ -68,20 +110,186 @@ func newContext(w http.ResponseWriter, req *http.Request) *Context {
  */
 func (c *Context) Next() {
 	c.index++
-	s := len(c.handlers)
-	for ; c.index < s; c.index++ {
+	for c.index < len(c.handlers) {
 		c.handlers[c.index](c)
+		// 被 Abort 之后 index 远大于 len(c.handlers)，各层的循环都会直接退出
+		c.index++
 	}
+	c.handleErrors()
+}
+
+/**
+ * 以 handlers 作为处理链执行，与匹配到路由后的执行方式相同（包括 ErrorHandler），
+ * 结束后发送还没有发出的响应头，配合 engine.CreateContext 使用
+ */
+func (c *Context) Run(handlers ...HandlerFunc) {
+	c.handlers = handlers
+	c.index = -1
+	c.Next()
+	c.Writer.WriteHeaderNow()
+}
+
+// 中间件链的长度上限，同时作为被 Abort 后的 index
+const abortIndex = math.MaxInt16
+
+// 阻止执行后续的中间件和 handler，当前函数和外层中间件 c.Next() 之后的代码仍会执行
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// 设置状态码并立即发送响应头，然后 Abort，例如鉴权失败时 AbortWithStatus(401)
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Writer.WriteHeaderNow()
+	c.Abort()
+}
+
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
+
+// 记录错误后 AbortWithStatus
+func (c *Context) AbortWithError(code int, err error) error {
+	c.AbortWithStatus(code)
+	return c.Error(err)
 }
 
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers) // 短路中间件的执行
+	c.Abort() // 短路中间件的执行
 	c.JSON(code, H{
 		"message": err,
 	})
 }
 
+// 记录处理过程中发生的错误，返回 err 本身，方便 return c.Error(err)
+func (c *Context) Error(err error) error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	c.Errors = append(c.Errors, err)
+	return err
+}
+
+// 一次请求中收集到的错误
+type ErrorList []error
+
+// 最后一个错误，没有错误时返回 nil
+func (list ErrorList) Last() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[len(list)-1]
+}
+
+func (list ErrorList) Error() string {
+	messages := make([]string, 0, len(list))
+	for i, err := range list {
+		messages = append(messages, fmt.Sprintf("Error #%02d: %s", i+1, err))
+	}
+	return strings.Join(messages, "\n")
+}
+
+// 在 Context 上保存键值对，handler 中可能开启 goroutine，因此需要加锁
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.Keys[key]
+	return
+}
+
+// key 不存在时 panic，用于一定由前置中间件设置的值
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic(fmt.Sprintf("gee: key %q does not exist", key))
+}
+
+// 不存在或类型不符时返回零值
+func (c *Context) GetString(key string) (s string) {
+	s, _ = GetAs[string](c, key)
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	b, _ = GetAs[bool](c, key)
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	i, _ = GetAs[int](c, key)
+	return
+}
+
+func (c *Context) GetInt64(key string) (i int64) {
+	i, _ = GetAs[int64](c, key)
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f float64) {
+	f, _ = GetAs[float64](c, key)
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	t, _ = GetAs[time.Time](c, key)
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	d, _ = GetAs[time.Duration](c, key)
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	ss, _ = GetAs[[]string](c, key)
+	return
+}
+
+/**
+ * 按类型取出 Context 上保存的值，不存在或类型不符时 ok 为 false
+ * 方法不能带类型参数，因此这里是一个函数，例如
+ * user, ok := gee.GetAs[*User](c, "user")
+ */
+func GetAs[T any](c *Context, key string) (value T, ok bool) {
+	v, exists := c.Get(key)
+	if !exists {
+		return value, false
+	}
+	value, ok = v.(T)
+	return value, ok
+}
+
+// key 不存在或类型不符时 panic
+func MustGetAs[T any](c *Context, key string) T {
+	value, ok := GetAs[T](c, key)
+	if !ok {
+		panic(fmt.Sprintf("gee: key %q does not exist or is not of type %T", key, value))
+	}
+	return value
+}
+
+// multipart 表单通过 c.MultipartForm 解析，遵守 engine.Upload 的限制
 func (c *Context) PostForm(key string) string {
+	if isMultipart(c.Req) {
+		if _, err := c.MultipartForm(); err != nil {
+			return ""
+		}
+	}
 	return c.Req.FormValue(key)
 }
 
@@ -94,6 +302,62 @@ func (c *Context) Param(key string) string {
 	return value
 }
 
+// 匹配到的路由 pattern，例如 /p/:lang/doc，没有匹配到路由时返回 ""
+// 用于日志、监控等需要按路由而不是按具体路径聚合的场景
+// 带可选参数的路由返回实际匹配的那一种展开形式，例如 /archive/:year<int>
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
+/**
+ * 客户端的 IP
+ * 只有直接连接的对端在 engine.SetTrustedProxies 设置的范围内时，才信任 X-Forwarded-For 和 X-Real-IP，
+ * 否则任何客户端都可以伪造这两个头
+ * X-Forwarded-For 从右向左跳过可信的代理，第一个不可信的地址就是客户端
+ */
+func (c *Context) ClientIP() string {
+	remoteIP, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		remoteIP = strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	if c.engine == nil || !c.engine.isTrustedProxy(net.ParseIP(remoteIP)) {
+		return remoteIP
+	}
+	if forwarded := c.Req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		items := strings.Split(forwarded, ",")
+		for i := len(items) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(items[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !c.engine.isTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+	}
+	if realIP := net.ParseIP(strings.TrimSpace(c.Req.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+	return remoteIP
+}
+
+/**
+ * 请求是否通过 HTTPS 到达
+ * 直接连接的对端是 engine.SetTrustedProxies 设置的代理时，同时信任它发送的 X-Forwarded-Proto
+ */
+func (c *Context) IsHTTPS() bool {
+	if c.Req.TLS != nil {
+		return true
+	}
+	remoteIP, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		remoteIP = strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return c.engine != nil && c.engine.isTrustedProxy(net.ParseIP(remoteIP)) &&
+		strings.EqualFold(c.Req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// 只记录状态码，响应头在第一次写入 body 时才发送
 func (c *Context) Status(code int) {
 	c.StatusCode = code
 	c.Writer.WriteHeader(code)
@@ -122,11 +386,3 @@ func (c *Context) Data(code int, data []byte) {
 	c.Status(code)
 	c.Writer.Write(data)
 }
-
-func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(500, err.Error())
-	}
-}
@@ -0,0 +1,246 @@
+package gee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 签名或加密的 cookie 被篡改、使用了未知的密钥或格式错误
+var ErrInvalidCookie = errors.New("gee: invalid cookie")
+
+// 没有调用 engine.SetCookieSecrets 就使用了签名或加密的 cookie
+var ErrNoCookieSecret = errors.New("gee: cookie secrets are not set")
+
+/**
+ * c.SetCookie 的选项，零值就是安全的默认值：
+ * Path 为 /，设置 HttpOnly，SameSite=Lax，请求是 HTTPS 时设置 Secure
+ */
+type CookieOptions struct {
+	Path   string // 为空时使用 /
+	Domain string
+	// 有效期（秒），0 表示浏览器关闭后失效的会话 cookie，小于 0 表示立即删除
+	MaxAge int
+	// 即使请求不是 HTTPS 也设置 Secure，例如 TLS 在不受信任的代理上终止时
+	Secure bool
+	// 允许页面脚本读取，即不设置 HttpOnly
+	AllowScript bool
+	// 为 0 时使用 http.SameSiteLaxMode
+	SameSite http.SameSite
+}
+
+// 读取请求中的 cookie，值经过 URL 解码，不存在时返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+// 设置 cookie，值经过 URL 编码，opts 省略时使用 CookieOptions 的默认值
+func (c *Context) SetCookie(name, value string, opts ...CookieOptions) {
+	var opt CookieOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Path == "" {
+		opt.Path = "/"
+	}
+	if opt.SameSite == 0 {
+		opt.SameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		Path:     opt.Path,
+		Domain:   opt.Domain,
+		MaxAge:   opt.MaxAge,
+		Secure:   opt.Secure || c.IsHTTPS() || opt.SameSite == http.SameSiteNoneMode,
+		HttpOnly: !opt.AllowScript,
+		SameSite: opt.SameSite,
+	})
+}
+
+// 删除 cookie，opts 中的 Path、Domain 必须与设置时相同
+func (c *Context) DeleteCookie(name string, opts ...CookieOptions) {
+	var opt CookieOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	opt.MaxAge = -1
+	c.SetCookie(name, "", opt)
+}
+
+/**
+ * 设置签名的 cookie，值对客户端可见，但无法被修改
+ * 签名包含 cookie 的名字，因此不能把一个 cookie 的值换到另一个 cookie 上
+ */
+func (c *Context) SetSignedCookie(name, value string, opts ...CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	c.SetCookie(name, codec.Sign(name, value), opts...)
+	return nil
+}
+
+// 读取签名的 cookie，签名不正确时返回 ErrInvalidCookie
+func (c *Context) SignedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.Verify(name, value)
+}
+
+// 设置加密的 cookie，使用 AES-GCM，客户端既不能读取也不能修改
+func (c *Context) SetEncryptedCookie(name, value string, opts ...CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	encrypted, err := codec.Encrypt(name, value)
+	if err != nil {
+		return err
+	}
+	c.SetCookie(name, encrypted, opts...)
+	return nil
+}
+
+// 读取加密的 cookie，无法解密时返回 ErrInvalidCookie
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return codec.Decrypt(name, value)
+}
+
+func (c *Context) cookieCodec() (*CookieCodec, error) {
+	if c.engine == nil || c.engine.cookieCodec == nil {
+		return nil, ErrNoCookieSecret
+	}
+	return c.engine.cookieCodec, nil
+}
+
+/**
+ * 设置签名和加密 cookie 使用的密钥
+ * 第一个密钥用于签名和加密，其余的只用于验证和解密，因此轮换密钥时把新密钥放在最前面，
+ * 旧密钥保留到它签发的 cookie 都过期为止
+ */
+func (engine *Engine) SetCookieSecrets(secrets ...string) {
+	engine.cookieCodec = NewCookieCodec(secrets...)
+}
+
+type cookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+/**
+ * 签名和加密 cookie 值的编解码器，engine.SetCookieSecrets 使用它，
+ * 也可以单独使用，例如 session 的 cookie 存储
+ * 每个密钥派生出独立的签名密钥（HMAC-SHA256）和加密密钥（AES-256-GCM）
+ */
+type CookieCodec struct {
+	keys []cookieKey
+}
+
+func NewCookieCodec(secrets ...string) *CookieCodec {
+	if len(secrets) == 0 {
+		panic("gee: at least one cookie secret is required")
+	}
+	codec := &CookieCodec{}
+	for _, secret := range secrets {
+		if secret == "" {
+			panic("gee: cookie secret must not be empty")
+		}
+		block, _ := aes.NewCipher(deriveKey(secret, "gee cookie encryption"))
+		aead, _ := cipher.NewGCM(block)
+		codec.keys = append(codec.keys, cookieKey{sign: deriveKey(secret, "gee cookie signing"), aead: aead})
+	}
+	return codec
+}
+
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func signature(key []byte, name, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+var cookieEncoding = base64.RawURLEncoding
+
+// 返回 base64(value).base64(签名)
+func (codec *CookieCodec) Sign(name, value string) string {
+	return cookieEncoding.EncodeToString([]byte(value)) + "." +
+		cookieEncoding.EncodeToString(signature(codec.keys[0].sign, name, value))
+}
+
+func (codec *CookieCodec) Verify(name, signed string) (string, error) {
+	encoded, sig, ok := strings.Cut(signed, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	value, err1 := cookieEncoding.DecodeString(encoded)
+	mac, err2 := cookieEncoding.DecodeString(sig)
+	if err1 != nil || err2 != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, key := range codec.keys {
+		if hmac.Equal(mac, signature(key.sign, name, string(value))) {
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+// 返回 base64(nonce + 密文)，cookie 的名字作为附加数据参与认证
+func (codec *CookieCodec) Encrypt(name, value string) (string, error) {
+	aead := codec.keys[0].aead
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return cookieEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), []byte(name))), nil
+}
+
+func (codec *CookieCodec) Decrypt(name, encrypted string) (string, error) {
+	data, err := cookieEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, key := range codec.keys {
+		size := key.aead.NonceSize()
+		if len(data) < size {
+			break
+		}
+		if value, err := key.aead.Open(nil, data[:size], data[size:], []byte(name)); err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
@@ -0,0 +1,76 @@
+package gee
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCookie(t *testing.T) {
+	r := New()
+	r.GET("/set", func(c *Context) {
+		c.SetCookie("plain", "a b;c")
+		c.SetSignedCookie("signed", "tom")
+		c.SetEncryptedCookie("secret", "42")
+	})
+	r.SetCookieSecrets("old")
+	oldSecret := httptest.NewRecorder()
+	r.ServeHTTP(oldSecret, httptest.NewRequest(http.MethodGet, "/set", nil))
+	// 轮换密钥：新密钥签发，旧密钥签发的 cookie 仍然有效
+	r.SetCookieSecrets("new", "old")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/set", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 3 {
+		t.Fatalf("expected 3 cookies, got %v", cookies)
+	}
+	plain := cookies[0]
+	if !plain.HttpOnly || plain.SameSite != http.SameSiteLaxMode || plain.Path != "/" || plain.Secure {
+		t.Fatalf("unexpected default cookie attributes %+v", plain)
+	}
+	if strings.Contains(cookies[2].Value, "42") {
+		t.Fatalf("encrypted cookie leaks its value: %s", cookies[2].Value)
+	}
+
+	read := func(cookies []*http.Cookie) (plain, signed, secret string, err error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		c := r.CreateContext(httptest.NewRecorder(), req)
+		var errs [3]error
+		plain, errs[0] = c.Cookie("plain")
+		signed, errs[1] = c.SignedCookie("signed")
+		secret, errs[2] = c.EncryptedCookie("secret")
+		for _, err := range errs {
+			if err != nil {
+				return plain, signed, secret, err
+			}
+		}
+		return plain, signed, secret, nil
+	}
+	for _, cookies := range [][]*http.Cookie{cookies, oldSecret.Result().Cookies()} {
+		plain, signed, secret, err := read(cookies)
+		if plain != "a b;c" || signed != "tom" || secret != "42" || err != nil {
+			t.Fatalf("unexpected cookie values %q %q %q %v", plain, signed, secret, err)
+		}
+	}
+
+	// 篡改签名 cookie 的值，或者把加密 cookie 换到另一个名字上
+	_, sig, _ := strings.Cut(cookies[1].Value, ".")
+	cookies[1].Value = "amVycnk." + sig
+	cookies[2].Name = "plain"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[1])
+	req.AddCookie(cookies[2])
+	c := r.CreateContext(httptest.NewRecorder(), req)
+	if _, err := c.SignedCookie("signed"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("tampered signed cookie should be rejected, got %v", err)
+	}
+	if _, err := r.cookieCodec.Decrypt("plain", cookies[2].Value); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("encrypted cookie should not decrypt under another name, got %v", err)
+	}
+}
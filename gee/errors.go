@@ -0,0 +1,194 @@
+package gee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const MIMEProblemJSON = "application/problem+json"
+
+/**
+ * 带有 HTTP 状态码的错误，交给 ErrorHandler 时按 Status 响应
+ * Detail 会返回给客户端；Err 是内部原因，只出现在日志（c.Errors）中
+ */
+type HTTPError struct {
+	Status int
+	Detail string
+	Err    error
+}
+
+func NewHTTPError(status int, detail string) *HTTPError {
+	return &HTTPError{Status: status, Detail: detail}
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+/**
+ * RFC 7807 定义的 problem details，以 application/problem+json 返回
+ * Type 为空时按 RFC 的约定视为 about:blank，Title 为空时使用状态码的描述
+ * Extensions 中的字段与标准字段平铺在同一个 JSON 对象中，例如校验错误的 errors
+ */
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// 以 application/problem+json 响应 p，Instance 为空时使用请求路径
+func (c *Context) Problem(p Problem) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = c.Path
+	}
+	b, err := json.Marshal(p)
+	c.writeJSON(p.Status, MIMEProblemJSON, b, err)
+}
+
+/**
+ * 默认的错误处理函数，把错误转换为 problem details：
+ *   *HTTPError                 使用它的状态码和 Detail
+ *   ValidationErrors           400，并在 errors 中列出每个字段的错误
+ *   *http.MaxBytesError        413
+ *   context.DeadlineExceeded   503
+ *   其他错误                   500，不向客户端暴露错误内容
+ */
+func DefaultErrorHandler(c *Context, err error) {
+	var httpErr *HTTPError
+	var verrs ValidationErrors
+	switch {
+	case errors.As(err, &httpErr):
+		c.Problem(Problem{Status: httpErr.Status, Detail: httpErr.Detail})
+	case errors.As(err, &verrs):
+		c.Problem(Problem{
+			Status:     http.StatusBadRequest,
+			Detail:     "validation failed",
+			Extensions: map[string]interface{}{"errors": verrs},
+		})
+	case isBodyTooLarge(err):
+		c.Problem(Problem{Status: http.StatusRequestEntityTooLarge, Detail: err.Error()})
+	case errors.Is(err, context.DeadlineExceeded):
+		c.Problem(Problem{Status: http.StatusServiceUnavailable})
+	default:
+		c.Problem(Problem{Status: http.StatusInternalServerError})
+	}
+}
+
+/**
+ * 处理链执行完毕后，c.Errors 不为空且还没有发出响应时，交给 ErrorHandler 生成响应
+ * 在最内层的 c.Next() 返回前执行，因此外层中间件（例如 Logger）看到的是最终的状态码
+ * handler 通过 c.Status 显式设置了状态码时（例如 c.Error(err); c.Status(204)）由它自己决定响应，
+ * 记录的错误只供中间件检查
+ * 每个请求最多执行一次
+ */
+func (c *Context) handleErrors() {
+	if c.StatusCode != 0 {
+		return
+	}
+	c.runErrorHandler()
+}
+
+func (c *Context) runErrorHandler() {
+	if len(c.Errors) == 0 || c.errorsHandled || c.Writer.Written() {
+		return
+	}
+	c.errorsHandled = true
+	handler := DefaultErrorHandler
+	if c.engine != nil && c.engine.ErrorHandler != nil {
+		handler = c.engine.ErrorHandler
+	}
+	handler(c, c.Errors.Last())
+}
+
+/**
+ * 把返回 error 的函数转换为 HandlerFunc，返回的错误会记录到 c.Errors 并中止处理链，例如
+ * r.GET("/articles/:id", gee.WrapE(func(c *gee.Context) error {
+ *     article, err := find(c.Param("id"))
+ *     if err != nil {
+ *         return gee.NewHTTPError(http.StatusNotFound, "article not found")
+ *     }
+ *     c.JSON(http.StatusOK, article)
+ *     return nil
+ * }))
+ */
+func WrapE(handler func(c *Context) error) HandlerFunc {
+	return func(c *Context) {
+		if err := handler(c); err != nil {
+			c.Error(err)
+			c.Abort()
+		}
+	}
+}
+
+/**
+ * 设置该分组（包括子分组）中没有匹配到路由时执行的 handlers，它们与所在分组的中间件组合后执行
+ * engine.NoRoute 对整个 engine 生效，engine.Host 或 Group 返回的分组可以各自覆盖
+ */
+func (group *RouterGroup) NoRoute(handlers ...HandlerFunc) {
+	group.noRoute = handlers
+}
+
+/**
+ * 设置路径存在但请求方式不匹配时执行的 handlers，执行前已经设置好了 Allow 响应头
+ * 没有显式注册的 OPTIONS 请求仍然自动以 204 应答
+ */
+func (group *RouterGroup) NoMethod(handlers ...HandlerFunc) {
+	group.noMethod = handlers
+}
+
+// 从当前分组向上查找第一个设置了 handlers 的分组
+func (group *RouterGroup) findHandlers(get func(g *RouterGroup) []HandlerFunc) []HandlerFunc {
+	for g := group; g != nil; g = g.parent {
+		if handlers := get(g); len(handlers) > 0 {
+			return handlers
+		}
+	}
+	return nil
+}
+
+func defaultNoRoute(c *Context) {
+	c.Error(NewHTTPError(http.StatusNotFound, fmt.Sprintf("no route for %s %s", c.Method, c.Path)))
+}
+
+func defaultNoMethod(c *Context) {
+	c.Error(NewHTTPError(http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed for %s", c.Method, c.Path)))
+}
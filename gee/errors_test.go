@@ -0,0 +1,113 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefaultErrorHandler(t *testing.T) {
+	var loggedStatus int
+	r := New()
+	r.Use(func(c *Context) {
+		c.Next()
+		loggedStatus = c.Writer.Status()
+	}, Recovery())
+	r.GET("/articles/:id", WrapE(func(c *Context) error {
+		return NewHTTPError(http.StatusNotFound, "article "+c.Param("id")+" not found")
+	}))
+	r.GET("/internal", func(c *Context) {
+		c.Error(errors.New("database is down"))
+	})
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	cases := []struct {
+		path   string
+		status int
+		detail string
+	}{
+		{"/articles/42", http.StatusNotFound, "article 42 not found"},
+		{"/internal", http.StatusInternalServerError, ""},
+		{"/panic", http.StatusInternalServerError, ""},
+		{"/missing", http.StatusNotFound, "no route for GET /missing"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.status || w.Header().Get("Content-Type") != MIMEProblemJSON {
+			t.Fatalf("%s: expected %d problem+json, got %d %s", c.path, c.status, w.Code, w.Header().Get("Content-Type"))
+		}
+		var problem map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem["status"] != float64(c.status) || problem["title"] != http.StatusText(c.status) ||
+			problem["instance"] != c.path || (problem["detail"] != nil && problem["detail"] != c.detail) {
+			t.Fatalf("%s: unexpected problem %v", c.path, problem)
+		}
+		// 外层中间件在 c.Next() 之后看到的是最终的状态码
+		if loggedStatus != c.status {
+			t.Fatalf("%s: middleware saw status %d", c.path, loggedStatus)
+		}
+	}
+}
+
+func TestErrorWithExplicitStatus(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	r.DELETE("/cache", func(c *Context) {
+		// 错误只用于记录，响应由 handler 决定
+		c.Error(errors.New("cache was already empty"))
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/panic", func(c *Context) {
+		c.Status(http.StatusOK)
+		panic("boom")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/cache", nil))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("explicit status should be kept, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != MIMEProblemJSON {
+		t.Fatalf("panic should still produce a 500 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestNoRouteNoMethod(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.SetHeader("X-Global", "1")
+		c.Next()
+	})
+	r.ErrorHandler = func(c *Context, err error) {
+		c.String(http.StatusTeapot, "custom: %v", err)
+	}
+	r.NoRoute(func(c *Context) {
+		c.JSON(http.StatusNotFound, H{"message": "nothing here"})
+	})
+	r.NoMethod(func(c *Context) {
+		c.Error(NewHTTPError(http.StatusMethodNotAllowed, "try "+c.Writer.Header().Get("Allow")))
+	})
+	r.GET("/items", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"message":"nothing here"}`+"\n" || w.Header().Get("X-Global") != "1" {
+		t.Fatalf("unexpected NoRoute response %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+	if w.Code != http.StatusTeapot || w.Body.String() != "custom: 405 Method Not Allowed: try GET, OPTIONS" {
+		t.Fatalf("unexpected NoMethod response %d %q", w.Code, w.Body.String())
+	}
+}
@@ -1,11 +1,15 @@
 package gee
 
 import (
+	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
-	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
 type HandlerFunc func(*Context)
@@ -13,7 +17,14 @@ type HandlerFunc func(*Context)
 type RouterGroup struct {
 	prefix      string
 	middlewares []HandlerFunc
+	parent      *RouterGroup // 父分组，中间件链按 engine -> ... -> parent -> group 的顺序组合
 	engine      *Engine
+	templateSet *TemplateSet // UseTemplateSet 指定的模板组，为 nil 时继承父分组
+	router      *router      // 分组所在主机的前缀树，engine.Host 创建的分组有自己的前缀树
+	host        *virtualHost // 分组所在的虚拟主机，为 nil 时属于 engine 本身
+	// NoRoute、NoMethod 设置的 handlers，为空时继承父分组
+	noRoute  []HandlerFunc
+	noMethod []HandlerFunc
 }
 
 // 实现 ServeHTTP 接口的一个 Gee 实例
@@ -25,13 +36,58 @@ type Engine struct {
 	// html 模版渲染
 	htmlTemplates *template.Template
 	funcMap       template.FuncMap
+	templateSets  map[string]*TemplateSet // AddTemplateSet 注册的命名模板组
+	// 按 MIME 类型注册的渲染器，renderOffers 记录注册顺序，作为内容协商的默认优先级
+	renderers    map[string]Renderer
+	renderOffers []string
+	// SecureJSON 在 JSON 数组前添加的前缀
+	SecureJSONPrefix string
+	// 复用 Context，减少每个请求的内存分配
+	pool sync.Pool
+
+	// 底层 http.Server 的配置，为 0 时使用 net/http 的默认值
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// 收到 SIGINT/SIGTERM 后等待进行中请求的最长时间，为 0 时一直等待
+	ShutdownTimeout time.Duration
+	// 开启明文 HTTP/2（h2c），需要 Go 1.24 及以上，较早的版本只会输出警告
+	UseH2C bool
+	// c.Upgrade 使用的 WebSocket 握手配置
+	Upgrader Upgrader
+
+	mu      sync.Mutex
+	servers map[*http.Server]struct{} // Run 系列方法启动的服务，用于 Shutdown
+
+	// 可信的反向代理，c.ClientIP 只信任来自它们的 X-Forwarded-For
+	trustedProxies []*net.IPNet
+
+	// 把 c.Errors 转换为响应的函数，为 nil 时使用 DefaultErrorHandler
+	ErrorHandler func(c *Context, err error)
+	// engine.Host 注册的虚拟主机，不带参数的在前
+	hosts []*virtualHost
+	// engine.SetCookieSecrets 设置的密钥，用于签名和加密 cookie
+	cookieCodec *CookieCodec
+	// 文件上传的限制
+	Upload UploadConfig
+
+	// 按注册顺序记录的路由，以及 Route.Name 命名的路由
+	routes      []*RouteInfo
+	namedRoutes map[string]*RouteInfo
 }
 
 // gee.Engine 的构造函数
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
-	engine.RouterGroup = &RouterGroup{engine: engine}
+	engine := &Engine{router: newRouter(), ShutdownTimeout: 10 * time.Second, SecureJSONPrefix: "while(1);"}
+	engine.namedRoutes = make(map[string]*RouteInfo)
+	engine.renderers, engine.renderOffers = defaultRenderers()
+	engine.RouterGroup = &RouterGroup{engine: engine, router: engine.router}
 	engine.groups = []*RouterGroup{engine.RouterGroup}
+	engine.pool.New = func() interface{} {
+		return &Context{engine: engine}
+	}
 	return engine
 }
 
@@ -41,19 +97,96 @@ func Default() *Engine {
 	return engine
 }
 
+// 中间件链在注册路由时就已经计算好并挂载在前缀树的叶子节点上，
+// 这里只需要查找路由，不再遍历所有分组
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var middlewares []HandlerFunc
-	// 直接循环加入有些太暴力？应该跟前缀树结合？
-	// 还有是不是应该去重？
-	for _, group := range engine.groups {
-		if strings.HasPrefix(req.URL.Path, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
+	c := engine.pool.Get().(*Context)
+	c.reset(w, req)
+	// 请求的 Host 匹配某个虚拟主机时，在该主机的前缀树中查找路由
+	root := engine.RouterGroup
+	vh, hostParams := engine.matchHost(req.Host)
+	if vh != nil {
+		root = vh.group
 	}
+	root.router.handle(c, root, hostParams)
+	// handler 只设置了状态码而没有写 body 时，在这里发送响应头
+	c.Writer.WriteHeaderNow()
+	engine.pool.Put(c)
+}
+
+/**
+ * 创建属于 engine 的 Context，不经过路由，用于在测试中直接调用 handler，例如
+ * c := engine.CreateContext(w, req)
+ * c.Params = map[string]string{"id": "42"}
+ * c.Run(handler)
+ * 返回的 Context 不来自 sync.Pool，handler 返回后仍然可以检查 c.Keys、c.Errors
+ */
+func (engine *Engine) CreateContext(w http.ResponseWriter, req *http.Request) *Context {
 	c := newContext(w, req)
-	c.handlers = middlewares
 	c.engine = engine
-	engine.router.handle(c)
+	c.group = engine.RouterGroup
+	return c
+}
+
+/**
+ * 在 root 所在的主机中找到与 path 匹配的最深的分组，用于 404/405 时组合中间件
+ * 分组前缀必须在路径段边界上匹配：/v1 匹配 /v1 和 /v1/x，不匹配 /v10/x
+ */
+func (engine *Engine) matchGroup(root *RouterGroup, path string) *RouterGroup {
+	matched := root
+	for _, group := range engine.groups {
+		if group.host != root.host {
+			continue
+		}
+		prefix := strings.TrimSuffix(group.prefix, "/")
+		if len(prefix) <= len(strings.TrimSuffix(matched.prefix, "/")) || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if len(path) == len(prefix) || path[len(prefix)] == '/' {
+			matched = group
+		}
+	}
+	return matched
+}
+
+/**
+ * 设置可信的反向代理，参数为 IP 或 CIDR，例如 []string{"10.0.0.0/8", "127.0.0.1"}
+ * 默认不信任任何代理，c.ClientIP 直接返回对端地址
+ */
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("gee: invalid trusted proxy %q", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("gee: invalid trusted proxy %q: %v", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	engine.trustedProxies = nets
+	return nil
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range engine.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
@@ -67,7 +200,16 @@ func (engine *Engine) LoadHTMLGlob(pattern string) {
 			ParseGlob(pattern))
 }
 
+// 与 LoadHTMLGlob 相同，但从 fs.FS（例如 embed.FS）中加载
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	engine.htmlTemplates = template.Must(
+		template.New("").
+			Funcs(engine.funcMap).
+			ParseFS(fsys, patterns...))
+}
+
 // 添加中间件
+// 中间件链在注册路由时计算，因此只对之后注册的路由生效
 func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
 	group.middlewares = append(group.middlewares, middlewares...)
 }
@@ -76,56 +218,111 @@ func (group *RouterGroup) Group(prefix string) *RouterGroup {
 	engine := group.engine
 	newGroup := &RouterGroup{
 		prefix: group.prefix + prefix,
+		parent: group,
 		engine: engine,
+		router: group.router,
+		host:   group.host,
 	}
 	engine.groups = append(engine.groups, newGroup)
 	return newGroup
 }
 
-func (group *RouterGroup) addRoute(method string, comp string, handler HandlerFunc) {
+// 按从外到内的顺序组合各级分组的中间件，最后追加 handlers
+func (group *RouterGroup) combineHandlers(handlers ...HandlerFunc) []HandlerFunc {
+	var groups []*RouterGroup
+	for g := group; g != nil; g = g.parent {
+		groups = append(groups, g)
+	}
+	chain := make([]HandlerFunc, 0)
+	for i := len(groups) - 1; i >= 0; i-- {
+		chain = append(chain, groups[i].middlewares...)
+	}
+	chain = append(chain, handlers...)
+	if len(chain) >= abortIndex {
+		panic("gee: too many handlers")
+	}
+	return chain
+}
+
+/**
+ * 注册路由，handlers 的最后一个是处理函数，之前的都是该路由独有的中间件
+ * 例如 GET("/admin", auth, handler)
+ */
+func (group *RouterGroup) addRoute(method string, comp string, handlers []HandlerFunc) *RouteInfo {
+	if len(handlers) == 0 {
+		panic("gee: there must be at least one handler")
+	}
 	pattern := group.prefix + comp
 	log.Printf("Route %4s - %s", method, pattern)
-	group.engine.router.addRoute(method, pattern, handler)
+	chain := group.combineHandlers(handlers...)
+	// 末尾的可选参数展开为多条路由，共享同一个处理链
+	for _, p := range expandOptional(pattern) {
+		leaf := group.router.addRoute(method, p, chain)
+		leaf.group = group
+	}
+
+	info := &RouteInfo{
+		Method:      method,
+		Path:        "/" + strings.Join(parsePattern(pattern), "/"),
+		Handler:     nameOfFunction(handlers[len(handlers)-1]),
+		HandlerFunc: handlers[len(handlers)-1],
+	}
+	if group.host != nil {
+		info.Host = group.host.pattern
+	}
+	group.engine.routes = append(group.engine.routes, info)
+	return info
 }
 
-func (group *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) newRoute(infos ...*RouteInfo) *Route {
+	return &Route{engine: group.engine, infos: infos}
 }
 
-func (group *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	group.addRoute("POST", pattern, handler)
+// 支持的全部请求方式，Any 会为其中每一种注册路由
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
 }
 
-func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+// 以任意请求方式注册路由，例如 WebDAV 的 PROPFIND
+func (group *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(method, pattern, handlers))
 }
 
-func (group *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(group.prefix, relativePath)
-	// 去除 Request.URL.Path 的前缀，因为 fileServer 默认使用 Request.URL.Path 作为文件路径
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		// 得到本地文件 root 下的相对路径
-		file := c.Param("filepath")
-		if _, err := fs.Open(file); err != nil {
-			c.Status(http.StatusNotFound)
-			return
-		}
+func (group *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodGet, pattern, handlers))
+}
 
-		// fileServer 是一个 HandlerFunc 类型
-		// fileServer.ServeHTTP(c.Writer, c.Req)
-		// -> fileServer(c.Writer, c.Req)
-		// 内部实现：-> http.FileServer(fs).ServeHTTP(c.Writer, c.Req)
-		fileServer.ServeHTTP(c.Writer, c.Req) // 需要手动调用
-		// engine 的 ServeHTTP 不需要手动调用的原因是在于
-		// 我们在 Run 函数的 ListenAndServe 中将其挂载了
-	}
+func (group *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodPost, pattern, handlers))
+}
+
+func (group *RouterGroup) PUT(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodPut, pattern, handlers))
+}
+
+func (group *RouterGroup) PATCH(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodPatch, pattern, handlers))
 }
 
-// 将静态文件夹本地根目录 root 映射到路由 relativePath/*filepath
-// 将文件路径提供给静态文件服务器后，剩下的都叫给静态文件服务器的 ServeHTTP 来处理
-func (group *RouterGroup) Static(relativePath string, root string) {
-	handler := group.createStaticHandler(relativePath, http.Dir(root))
-	urlPattern := path.Join(relativePath, "/*filepath")
-	group.GET(urlPattern, handler)
+func (group *RouterGroup) DELETE(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodDelete, pattern, handlers))
+}
+
+func (group *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodHead, pattern, handlers))
+}
+
+// 显式注册的 OPTIONS 路由优先于 router 自动生成的应答
+func (group *RouterGroup) OPTIONS(pattern string, handlers ...HandlerFunc) *Route {
+	return group.newRoute(group.addRoute(http.MethodOptions, pattern, handlers))
+}
+
+// 为 anyMethods 中的每一种请求方式注册同一组 handlers
+func (group *RouterGroup) Any(pattern string, handlers ...HandlerFunc) *Route {
+	infos := make([]*RouteInfo, len(anyMethods))
+	for i, method := range anyMethods {
+		infos[i] = group.addRoute(method, pattern, handlers)
+	}
+	return group.newRoute(infos...)
 }
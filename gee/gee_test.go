@@ -2,6 +2,8 @@ package gee
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 )
@@ -44,3 +46,268 @@ func TestGetRoute(t *testing.T) {
 	fmt.Printf("matched path: %s, params['name']: %s\n", n.pattern, ps["name"])
 
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	r := New()
+	r.GET("/hello/:name", func(c *Context) {})
+	r.PUT("/hello/:name", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/hello/geektutu", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expect 405, got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, OPTIONS, PUT" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/hello/geektutu", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, OPTIONS, PUT" {
+		t.Fatalf("OPTIONS should be answered automatically, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expect 404, got %d", w.Code)
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	r := newRouter()
+	// 参数路由先注册，也不能遮蔽静态路由
+	r.addRoute("GET", "/p/:lang", nil)
+	r.addRoute("GET", "/p/doc", nil)
+	r.addRoute("GET", "/p/:lang/intro", nil)
+	r.addRoute("GET", "/p/doc/x", nil)
+	r.addRoute("GET", "/p/*filepath", nil)
+
+	cases := []struct {
+		path, pattern, key, value string
+	}{
+		{"/p/doc", "/p/doc", "", ""},
+		{"/p/go", "/p/:lang", "lang", "go"},
+		{"/p/doc/intro", "/p/:lang/intro", "lang", "doc"}, // 静态分支失败后回溯
+		{"/p/doc/x", "/p/doc/x", "", ""},
+		{"/p/go/a/b", "/p/*filepath", "filepath", "go/a/b"},
+		{"/p//go/", "/p/:lang", "lang", "go"},
+	}
+	for _, c := range cases {
+		n, ps := r.getRoute("GET", c.path)
+		if n == nil || n.pattern != c.pattern {
+			t.Fatalf("%s should match %s, got %v", c.path, c.pattern, n)
+		}
+		if c.key != "" && ps[c.key] != c.value {
+			t.Fatalf("%s: params[%s] should be %s, got %s", c.path, c.key, c.value, ps[c.key])
+		}
+	}
+}
+
+func TestRouteConflict(t *testing.T) {
+	patterns := [][2]string{
+		{"/hello/:name", "/hello/:name"},
+		{"/hello/:name", "/hello/:id/doc"},
+		{"/assets/*filepath", "/assets/*file"},
+		{"/assets/*filepath/x", ""},
+		{"/hello/:", ""},
+		{"/user/:id<int>", "/user/:uid<int>"},
+		{"/user/:id<[0-9+>", ""},
+	}
+	for _, p := range patterns {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("registering %v should panic", p)
+				}
+			}()
+			r := newRouter()
+			for _, pattern := range p {
+				if pattern != "" {
+					r.addRoute("GET", pattern, nil)
+				}
+			}
+		}()
+	}
+}
+
+func TestParamConstraints(t *testing.T) {
+	r := New()
+	handler := func(c *Context) {
+		c.String(http.StatusOK, "%s %v", c.FullPath(), c.Params)
+	}
+	r.GET("/user/new", handler)
+	r.GET("/user/:name", handler)
+	r.GET("/user/:id<int>", func(c *Context) {
+		id, err := c.ParamInt("id")
+		c.String(http.StatusOK, "int %d %v", id, err)
+	})
+	r.GET("/user/:uuid<uuid>", handler)
+	r.GET("/file/:name<[a-z]+\\.txt>", handler)
+	r.GET("/archive/:year<int>/:month<int>?", handler)
+
+	cases := []struct {
+		path, body string
+	}{
+		{"/user/new", "/user/new map[]"},
+		{"/user/42", "int 42 <nil>"},
+		{"/user/0b3c6a1e-8f2d-4c1a-9e5b-7d6f8a9b0c1d", "/user/:uuid<uuid> map[uuid:0b3c6a1e-8f2d-4c1a-9e5b-7d6f8a9b0c1d]"},
+		{"/user/tom", "/user/:name map[name:tom]"}, // 不满足约束时落到不带约束的参数
+		{"/file/notes.txt", "/file/:name<[a-z]+\\.txt> map[name:notes.txt]"},
+		{"/archive/2024", "/archive/:year<int> map[year:2024]"},
+		{"/archive/2024/05", "/archive/:year<int>/:month<int> map[month:05 year:2024]"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != c.body {
+			t.Fatalf("%s: expected %q, got %d %q", c.path, c.body, w.Code, w.Body.String())
+		}
+	}
+	for _, path := range []string{"/file/Notes.txt", "/file/notes.md", "/archive/2024/may", "/archive/latest"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+
+	r.GET("/posts/:id<int>/:slug?", handler).Name("post")
+	if u, err := r.URL("post", map[string]string{"id": "7"}); err != nil || u != "/posts/7" {
+		t.Fatalf("unexpected URL %q %v", u, err)
+	}
+	if _, err := r.URL("post", map[string]string{"id": "seven"}); err == nil {
+		t.Fatal("expected an error for a value that violates the constraint")
+	}
+}
+
+func TestStaticRouteAllocs(t *testing.T) {
+	r := newTestRouter()
+	allocs := testing.AllocsPerRun(100, func() {
+		r.getRoute("GET", "/hello/b/c")
+	})
+	if allocs != 0 {
+		t.Fatalf("static lookup should not allocate, got %v allocs", allocs)
+	}
+}
+
+func BenchmarkGetRouteStatic(b *testing.B) {
+	r := newTestRouter()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/hello/b/c")
+	}
+}
+
+func BenchmarkGetRouteParam(b *testing.B) {
+	r := newTestRouter()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/hello/geektutu")
+	}
+}
+
+func BenchmarkGetRouteCatchAll(b *testing.B) {
+	r := newTestRouter()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.getRoute("GET", "/assets/css/geektutu.css")
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	r := New()
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	r.Use(mark("global"))
+	v1 := r.Group("/v1")
+	v1.Use(mark("v1"))
+	v1.GET("/hello", mark("route"), func(c *Context) { trace = append(trace, "handler") })
+	v10 := r.Group("/v10")
+	v10.GET("/hello", func(c *Context) { trace = append(trace, "handler") })
+
+	cases := []struct {
+		path   string
+		expect string
+	}{
+		{"/v1/hello", "[global v1 route handler]"},
+		{"/v10/hello", "[global handler]"}, // /v1 的中间件不能作用于 /v10
+		{"/v1/nothing", "[global v1]"},     // 404 也会经过所在分组的中间件
+		{"/v10/nothing", "[global]"},
+	}
+	for _, c := range cases {
+		trace = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+		if got := fmt.Sprint(trace); got != c.expect {
+			t.Fatalf("%s: expect %s, got %s", c.path, c.expect, got)
+		}
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	r.GET("/fail", func(c *Context) {
+		c.Status(http.StatusOK)
+		c.Fail(http.StatusInternalServerError, "oops") // 还没有写 body，状态码可以被覆盖
+	})
+	r.GET("/partial", func(c *Context) {
+		c.String(http.StatusOK, "partial")
+		panic("after write")
+	})
+	r.GET("/empty", func(c *Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/fail", http.StatusInternalServerError, "{\"message\":\"oops\"}\n"},
+		{"/partial", http.StatusOK, "partial"},
+		{"/empty", http.StatusNoContent, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Fatalf("%s: expect %d %q, got %d %q", c.path, c.code, c.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAbort(t *testing.T) {
+	r := New()
+	var trace []string
+	r.Use(func(c *Context) {
+		c.Next()
+		trace = append(trace, fmt.Sprintf("aborted=%v errors=%d", c.IsAborted(), len(c.Errors)))
+	})
+	auth := func(c *Context) {
+		c.Set("user", "geektutu")
+		c.Next()
+	}
+	deny := func(c *Context) {
+		if user, ok := GetAs[string](c, "user"); !ok || user != "geektutu" {
+			t.Fatal("user should be set by auth middleware")
+		}
+		c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("denied"))
+		trace = append(trace, "deny")
+	}
+	r.GET("/admin", auth, deny, func(c *Context) { trace = append(trace, "handler") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %d", w.Code)
+	}
+	if got := fmt.Sprint(trace); got != "[deny aborted=true errors=1]" {
+		t.Fatalf("unexpected trace %s", got)
+	}
+}
@@ -0,0 +1,184 @@
+package geetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// *testing.T、*testing.B 都满足这个接口
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+/**
+ * 对响应的断言，失败时通过 t.Errorf 报告并继续检查后面的断言，
+ * 每个方法都返回自身以便链式调用
+ */
+type Expect struct {
+	t    TestingT
+	resp *Response
+}
+
+// 返回被检查的响应，用于链式断言之外的检查
+func (e *Expect) Response() *Response {
+	return e.resp
+}
+
+func (e *Expect) Status(code int) *Expect {
+	e.t.Helper()
+	if e.resp.Code != code {
+		e.t.Errorf("expected status %d, got %d, body: %s", code, e.resp.Code, e.resp.Body.String())
+	}
+	return e
+}
+
+func (e *Expect) Header(key, value string) *Expect {
+	e.t.Helper()
+	if got := e.resp.Header().Get(key); got != value {
+		e.t.Errorf("expected header %s: %q, got %q", key, value, got)
+	}
+	return e
+}
+
+func (e *Expect) ContentType(contentType string) *Expect {
+	e.t.Helper()
+	return e.Header("Content-Type", contentType)
+}
+
+func (e *Expect) Body(body string) *Expect {
+	e.t.Helper()
+	if got := e.resp.Body.String(); got != body {
+		e.t.Errorf("expected body %q, got %q", body, got)
+	}
+	return e
+}
+
+func (e *Expect) BodyContains(s string) *Expect {
+	e.t.Helper()
+	if !strings.Contains(e.resp.Body.String(), s) {
+		e.t.Errorf("expected body to contain %q, got %q", s, e.resp.Body.String())
+	}
+	return e
+}
+
+/**
+ * 比较响应体与 expected 的 JSON 是否相等，两边都先序列化再解析后比较，
+ * 因此 expected 可以是 gee.H、结构体或者 JSON 字符串（json.RawMessage）
+ */
+func (e *Expect) JSON(expected interface{}) *Expect {
+	e.t.Helper()
+	var got interface{}
+	if err := e.resp.DecodeJSON(&got); err != nil {
+		e.t.Errorf("response is not valid JSON: %v, body: %s", err, e.resp.Body.String())
+		return e
+	}
+	want, err := normalizeJSON(expected)
+	if err != nil {
+		e.t.Errorf("cannot encode expected value: %v", err)
+		return e
+	}
+	if !reflect.DeepEqual(got, want) {
+		e.t.Errorf("expected JSON %s, got %s", mustMarshal(want), e.resp.Body.String())
+	}
+	return e
+}
+
+/**
+ * 检查响应体中 path 指向的值，path 的写法见 lookupJSONPath，例如
+ * JSONPath("$.name", "Tom")
+ * JSONPath("$.items[0].id", 1)
+ * 数字统一按 float64 比较，所以 expected 写 1 或 1.0 都可以
+ */
+func (e *Expect) JSONPath(path string, expected interface{}) *Expect {
+	e.t.Helper()
+	var doc interface{}
+	if err := e.resp.DecodeJSON(&doc); err != nil {
+		e.t.Errorf("response is not valid JSON: %v, body: %s", err, e.resp.Body.String())
+		return e
+	}
+	got, err := lookupJSONPath(doc, path)
+	if err != nil {
+		e.t.Errorf("%s: %v, body: %s", path, err, e.resp.Body.String())
+		return e
+	}
+	want, err := normalizeJSON(expected)
+	if err != nil {
+		e.t.Errorf("cannot encode expected value: %v", err)
+		return e
+	}
+	if !reflect.DeepEqual(got, want) {
+		e.t.Errorf("%s: expected %s, got %s", path, mustMarshal(want), mustMarshal(got))
+	}
+	return e
+}
+
+// 检查处理过程中渲染过名为 name 的模板
+func (e *Expect) Template(name string) *Expect {
+	e.t.Helper()
+	names := make([]string, len(e.resp.Templates))
+	for i, tmpl := range e.resp.Templates {
+		if tmpl.Name == name {
+			return e
+		}
+		names[i] = tmpl.Name
+	}
+	e.t.Errorf("expected template %q to be rendered, rendered: %v", name, names)
+	return e
+}
+
+// 检查最后一次渲染名为 name 的模板时传入的数据
+func (e *Expect) TemplateData(name string, expected interface{}) *Expect {
+	e.t.Helper()
+	for i := len(e.resp.Templates) - 1; i >= 0; i-- {
+		if tmpl := e.resp.Templates[i]; tmpl.Name == name {
+			if !reflect.DeepEqual(tmpl.Data, expected) {
+				e.t.Errorf("template %q: expected data %#v, got %#v", name, expected, tmpl.Data)
+			}
+			return e
+		}
+	}
+	e.t.Errorf("expected template %q to be rendered", name)
+	return e
+}
+
+func (e *Expect) Cookie(name, value string) *Expect {
+	e.t.Helper()
+	for _, cookie := range e.resp.Result().Cookies() {
+		if cookie.Name == name {
+			if cookie.Value != value {
+				e.t.Errorf("expected cookie %s=%q, got %q", name, value, cookie.Value)
+			}
+			return e
+		}
+	}
+	e.t.Errorf("expected cookie %s to be set", name)
+	return e
+}
+
+// 把 v 转换为 json.Unmarshal 到 interface{} 得到的形式，json.RawMessage 之外的值先序列化
+func normalizeJSON(v interface{}) (interface{}, error) {
+	var b []byte
+	switch v := v.(type) {
+	case json.RawMessage:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var out interface{}
+	err := json.Unmarshal(b, &out)
+	return out, err
+}
+
+func mustMarshal(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,234 @@
+/**
+ * geetest 在进程内测试 gee 的 handler，不需要启动服务，例如
+ * client := geetest.New(engine)
+ * client.POST("/users").WithJSON(gee.H{"name": "Tom"}).
+ *     Expect(t).Status(http.StatusCreated).JSONPath("$.name", "Tom")
+ *
+ * 也可以不经过路由，用伪造的 Context 直接调用单个 handler：
+ * client.GET("/users/42").WithParam("id", "42").Call(showUser).
+ *     Expect(t).Status(http.StatusOK)
+ */
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"gee"
+)
+
+/**
+ * 发送测试请求的客户端，保存响应设置的 cookie 并在之后的请求中带上，
+ * 因此可以测试登录、session 这类跨请求的流程
+ */
+type Client struct {
+	engine *gee.Engine
+	jar    http.CookieJar
+	// 每个请求都会带上的请求头，例如 Authorization
+	Header http.Header
+}
+
+// engine 为 nil 时使用 gee.New()，只用于 Call 直接调用 handler
+func New(engine *gee.Engine) *Client {
+	if engine == nil {
+		engine = gee.New()
+	}
+	jar, _ := cookiejar.New(nil)
+	return &Client{engine: engine, jar: jar, Header: make(http.Header)}
+}
+
+func (cl *Client) GET(path string) *Request     { return cl.Request(http.MethodGet, path) }
+func (cl *Client) POST(path string) *Request    { return cl.Request(http.MethodPost, path) }
+func (cl *Client) PUT(path string) *Request     { return cl.Request(http.MethodPut, path) }
+func (cl *Client) PATCH(path string) *Request   { return cl.Request(http.MethodPatch, path) }
+func (cl *Client) DELETE(path string) *Request  { return cl.Request(http.MethodDelete, path) }
+func (cl *Client) HEAD(path string) *Request    { return cl.Request(http.MethodHead, path) }
+func (cl *Client) OPTIONS(path string) *Request { return cl.Request(http.MethodOptions, path) }
+
+// path 可以带查询参数，例如 /users?page=2
+func (cl *Client) Request(method, path string) *Request {
+	return &Request{
+		client: cl,
+		method: method,
+		path:   path,
+		header: cl.Header.Clone(),
+		query:  make(url.Values),
+	}
+}
+
+/**
+ * 构造中的请求，With 系列方法返回自身以便链式调用
+ * 构造过程中的错误（例如 WithJSON 序列化失败）在发送时 panic，它们属于测试代码本身的错误
+ */
+type Request struct {
+	client  *Client
+	method  string
+	path    string
+	host    string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	params  map[string]string
+	body    []byte
+	err     error
+}
+
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+// 追加查询参数，与 path 中已有的查询参数合并
+func (r *Request) WithQuery(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// 设置请求的 Host，用于测试 engine.Host 注册的虚拟主机
+func (r *Request) WithHost(host string) *Request {
+	r.host = host
+	return r
+}
+
+func (r *Request) WithCookie(cookie *http.Cookie) *Request {
+	r.cookies = append(r.cookies, cookie)
+	return r
+}
+
+// 设置路由参数，只对 Call 有效，经过路由的请求由路由解析参数
+func (r *Request) WithParam(key, value string) *Request {
+	if r.params == nil {
+		r.params = make(map[string]string)
+	}
+	r.params[key] = value
+	return r
+}
+
+func (r *Request) WithBody(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+func (r *Request) WithJSON(obj interface{}) *Request {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		r.err = err
+	}
+	return r.WithBody(gee.MIMEJSON, b)
+}
+
+func (r *Request) WithForm(form url.Values) *Request {
+	return r.WithBody("application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// 构造 *http.Request，请求的 context 中带有记录模板渲染的 recorder
+func (r *Request) build() (*http.Request, *templateRecorder) {
+	if r.err != nil {
+		panic("geetest: " + r.err.Error())
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req := httptest.NewRequest(r.method, r.path, body)
+	if len(r.query) > 0 {
+		q := req.URL.Query()
+		for k, vs := range r.query {
+			q[k] = append(q[k], vs...)
+		}
+		req.URL.RawQuery = q.Encode()
+		req.RequestURI = req.URL.RequestURI()
+	}
+	if r.host != "" {
+		req.Host = r.host
+	}
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+	for _, cookie := range r.client.jar.Cookies(r.cookieURL(req)) {
+		req.AddCookie(cookie)
+	}
+	for _, cookie := range r.cookies {
+		req.AddCookie(cookie)
+	}
+	rec := &templateRecorder{}
+	return req.WithContext(gee.WithTemplateRecorder(req.Context(), rec)), rec
+}
+
+// cookie jar 按 URL 保存 cookie，这里统一使用 http 协议和请求的 Host
+func (r *Request) cookieURL(req *http.Request) *url.URL {
+	return &url.URL{Scheme: "http", Host: req.Host, Path: req.URL.Path}
+}
+
+// 经过 engine 的路由和中间件发送请求
+func (r *Request) Do() *Response {
+	req, rec := r.build()
+	w := httptest.NewRecorder()
+	r.client.engine.ServeHTTP(w, req)
+	return r.response(req, w, rec, nil)
+}
+
+/**
+ * 不经过路由，用伪造的 Context 依次执行 handlers，WithParam 设置的参数放在 c.Params 中
+ * 返回的 Response.Context 可以用来检查 handler 设置的 c.Keys 和 c.Errors
+ */
+func (r *Request) Call(handlers ...gee.HandlerFunc) *Response {
+	req, rec := r.build()
+	w := httptest.NewRecorder()
+	c := r.client.engine.CreateContext(w, req)
+	c.Params = r.params
+	c.Run(handlers...)
+	return r.response(req, w, rec, c)
+}
+
+func (r *Request) response(req *http.Request, w *httptest.ResponseRecorder, rec *templateRecorder, c *gee.Context) *Response {
+	r.client.jar.SetCookies(r.cookieURL(req), w.Result().Cookies())
+	return &Response{ResponseRecorder: w, Templates: rec.templates, Context: c}
+}
+
+// 发送请求并返回对响应的断言
+func (r *Request) Expect(t TestingT) *Expect {
+	return r.Do().Expect(t)
+}
+
+// 一次 c.HTML 调用渲染的模板
+type RenderedTemplate struct {
+	Name string
+	Data interface{}
+}
+
+type Response struct {
+	*httptest.ResponseRecorder
+	// 处理过程中按顺序渲染的模板
+	Templates []RenderedTemplate
+	// Call 使用的 Context，Do 发送的请求为 nil
+	Context *gee.Context
+}
+
+func (resp *Response) Expect(t TestingT) *Expect {
+	return &Expect{t: t, resp: resp}
+}
+
+// 把响应体解析为 JSON 到 v
+func (resp *Response) DecodeJSON(v interface{}) error {
+	return json.NewDecoder(strings.NewReader(resp.Body.String())).Decode(v)
+}
+
+type templateRecorder struct {
+	mu        sync.Mutex
+	templates []RenderedTemplate
+}
+
+func (rec *templateRecorder) RecordTemplate(name string, data interface{}) {
+	rec.mu.Lock()
+	rec.templates = append(rec.templates, RenderedTemplate{Name: name, Data: data})
+	rec.mu.Unlock()
+}
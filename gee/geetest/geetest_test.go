@@ -0,0 +1,105 @@
+package geetest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"testing/fstest"
+
+	"gee"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" binding:"required"`
+}
+
+func newEngine() *gee.Engine {
+	r := gee.New()
+	r.LoadHTMLFS(fstest.MapFS{"user.tmpl": {Data: []byte(`{{define "user"}}<p>{{.Name}}</p>{{end}}`)}}, "*.tmpl")
+	r.POST("/users", func(c *gee.Context) {
+		var u user
+		if c.BindJSON(&u) != nil {
+			return
+		}
+		u.ID = 1
+		c.SetHeader("X-Token", c.Req.Header.Get("X-Token"))
+		c.JSON(http.StatusCreated, gee.H{"user": u, "tags": []string{"a", "b"}, "page": c.Query("page")})
+	})
+	r.GET("/users/:id", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "user", user{Name: "Tom"})
+	})
+	r.GET("/login", func(c *gee.Context) {
+		http.SetCookie(c.Writer, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+	})
+	r.GET("/me", func(c *gee.Context) {
+		cookie, err := c.Req.Cookie("session")
+		if err != nil {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.String(http.StatusOK, "%s", cookie.Value)
+	})
+	return r
+}
+
+func TestClient(t *testing.T) {
+	client := New(newEngine())
+	client.POST("/users?page=2").WithHeader("X-Token", "abc").WithJSON(gee.H{"name": "Tom"}).
+		Expect(t).
+		Status(http.StatusCreated).
+		Header("X-Token", "abc").
+		JSONPath("$.user.name", "Tom").
+		JSONPath("$.user.id", 1).
+		JSONPath("$.tags[-1]", "b").
+		JSONPath("$['page']", "2").
+		JSON(gee.H{"user": user{ID: 1, Name: "Tom"}, "tags": []string{"a", "b"}, "page": "2"})
+
+	client.GET("/users/1").Expect(t).
+		Status(http.StatusOK).
+		Template("user").
+		TemplateData("user", user{Name: "Tom"}).
+		Body("<p>Tom</p>")
+
+	client.GET("/me").Expect(t).Status(http.StatusUnauthorized)
+	client.GET("/login").Expect(t).Cookie("session", "s1")
+	client.GET("/me").Expect(t).Status(http.StatusOK).Body("s1")
+}
+
+func TestCall(t *testing.T) {
+	handler := func(c *gee.Context) {
+		c.Set("user", c.Param("id"))
+		c.Error(gee.NewHTTPError(http.StatusNotFound, "user "+c.Param("id")+" not found"))
+	}
+	resp := New(nil).GET("/users/42").WithParam("id", "42").Call(handler)
+	resp.Expect(t).
+		Status(http.StatusNotFound).
+		ContentType(gee.MIMEProblemJSON).
+		JSONPath("$.detail", "user 42 not found")
+	if resp.Context.GetString("user") != "42" {
+		t.Fatalf("unexpected context keys %v", resp.Context.Keys)
+	}
+}
+
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpectFailures(t *testing.T) {
+	rt := &recordingT{}
+	New(newEngine()).POST("/users").WithJSON(gee.H{"name": "Tom"}).Expect(rt).
+		Status(http.StatusOK).
+		JSONPath("$.user.name", "Jerry").
+		JSONPath("$.missing", 1).
+		JSONPath("$.tags[5]", "a").
+		Template("user")
+	if len(rt.errors) != 5 {
+		t.Fatalf("expected 5 failures, got %d: %v", len(rt.errors), rt.errors)
+	}
+}
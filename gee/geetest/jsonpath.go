@@ -0,0 +1,90 @@
+package geetest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/**
+ * 在解析后的 JSON 中查找 path 指向的值，支持 JSONPath 的一个子集：
+ * $            根节点
+ * .name        对象的字段
+ * ['a.b']      字段名包含特殊字符时使用
+ * [0]、[-1]    数组下标，负数从末尾开始
+ */
+func lookupJSONPath(doc interface{}, path string) (interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	cur := doc
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty field name")
+			}
+			v, err := field(cur, key)
+			if err != nil {
+				return nil, err
+			}
+			cur, rest = v, rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ]")
+			}
+			sel := rest[1:end]
+			var v interface{}
+			var err error
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0] {
+				v, err = field(cur, sel[1:len(sel)-1])
+			} else {
+				v, err = index(cur, sel)
+			}
+			if err != nil {
+				return nil, err
+			}
+			cur, rest = v, rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	return cur, nil
+}
+
+func field(v interface{}, key string) (interface{}, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot get field %q of %T", key, v)
+	}
+	value, ok := obj[key]
+	if !ok {
+		return nil, fmt.Errorf("field %q not found", key)
+	}
+	return value, nil
+}
+
+func index(v interface{}, sel string) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot index %T", v)
+	}
+	i, err := strconv.Atoi(sel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid index %q", sel)
+	}
+	if i < 0 {
+		i += len(arr)
+	}
+	if i < 0 || i >= len(arr) {
+		return nil, fmt.Errorf("index %s out of range (len %d)", sel, len(arr))
+	}
+	return arr[i], nil
+}
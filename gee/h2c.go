@@ -0,0 +1,12 @@
+//go:build go1.24
+
+package gee
+
+import "net/http"
+
+// 明文 HTTP/2（h2c），只建议在内网或前面有 TLS 终结的代理时使用
+func enableH2C(srv *http.Server) {
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+}
@@ -0,0 +1,13 @@
+//go:build !go1.24
+
+package gee
+
+import (
+	"log"
+	"net/http"
+)
+
+// Go 1.24 之前 net/http 不支持 h2c，继续只提供 HTTP/1.1
+func enableH2C(srv *http.Server) {
+	log.Printf("[WARNING] UseH2C requires Go 1.24 or later, %s serves HTTP/1.1 only", srv.Addr)
+}
@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"fmt"
+	"strings"
+)
+
+/**
+ * 虚拟主机，每个主机有自己的前缀树和分组
+ * 主机 pattern 按 . 切分为标签，:name 匹配一个完整的标签，例如
+ * api.example.com      只匹配 api.example.com
+ * :tenant.example.com  匹配 acme.example.com，c.Param("tenant") 为 acme
+ */
+type virtualHost struct {
+	pattern string
+	labels  []string
+	group   *RouterGroup // 主机的根分组
+}
+
+// 按标签匹配主机名，成功时返回主机参数，没有参数时为 nil
+func (vh *virtualHost) match(labels []string) (bool, map[string]string) {
+	if len(labels) != len(vh.labels) {
+		return false, nil
+	}
+	var params map[string]string
+	for i, label := range vh.labels {
+		if label[0] == ':' {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[label[1:]] = labels[i]
+			continue
+		}
+		if label != labels[i] {
+			return false, nil
+		}
+	}
+	return true, params
+}
+
+/**
+ * 返回主机 pattern 对应的分组，同一个 pattern 多次调用返回同一个分组
+ * 分组有自己的前缀树：请求的 Host 匹配时只在这里查找路由，都不匹配时使用 engine 本身的路由
+ * 不带参数的主机优先于带参数的主机，带参数的主机按注册顺序匹配
+ * 主机参数与路径参数一起放在 c.Params 中，重名时以路径参数为准
+ *
+ * 主机分组的父分组是 engine，因此 engine.Use 注册的全局中间件同样生效；
+ * 主机分组自己的中间件、NoRoute 和 NoMethod 只作用于该主机
+ * pattern 不区分大小写，不包含端口，匹配时会忽略请求中的端口
+ */
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	for _, vh := range engine.hosts {
+		if vh.pattern == pattern {
+			return vh.group
+		}
+	}
+	labels := strings.Split(pattern, ".")
+	for _, label := range labels {
+		if label == "" || label == ":" {
+			panic(fmt.Sprintf("gee: invalid host pattern %q", pattern))
+		}
+	}
+
+	vh := &virtualHost{pattern: pattern, labels: labels}
+	vh.group = &RouterGroup{
+		parent: engine.RouterGroup,
+		engine: engine,
+		router: newRouter(),
+		host:   vh,
+	}
+	engine.groups = append(engine.groups, vh.group)
+	if strings.Contains(pattern, ":") {
+		engine.hosts = append(engine.hosts, vh)
+	} else {
+		// 不带参数的主机排在带参数的主机之前
+		i := 0
+		for i < len(engine.hosts) && !strings.Contains(engine.hosts[i].pattern, ":") {
+			i++
+		}
+		engine.hosts = append(engine.hosts[:i], append([]*virtualHost{vh}, engine.hosts[i:]...)...)
+	}
+	return vh.group
+}
+
+// 根据请求的 Host 查找虚拟主机，没有匹配时返回 nil
+func (engine *Engine) matchHost(host string) (*virtualHost, map[string]string) {
+	if len(engine.hosts) == 0 {
+		return nil, nil
+	}
+	// 去掉端口，IPv6 地址形如 [::1]:8080
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	labels := strings.Split(host, ".")
+	for _, vh := range engine.hosts {
+		if ok, params := vh.match(labels); ok {
+			return vh, params
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,68 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "default") })
+
+	api := r.Host("api.example.com")
+	api.Use(func(c *Context) {
+		c.SetHeader("X-Host", "api")
+		c.Next()
+	})
+	api.GET("/", func(c *Context) { c.String(http.StatusOK, "api") })
+	api.NoRoute(func(c *Context) { c.String(http.StatusNotFound, "api: no route") })
+
+	tenant := r.Host(":tenant.example.com")
+	tenant.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s/%s", c.Param("tenant"), c.Param("id"))
+	})
+	if r.Host("API.example.com.") != api {
+		t.Fatal("the same host pattern should return the same group")
+	}
+
+	cases := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{"api.example.com", "/", http.StatusOK, "api"},
+		{"API.example.com:8080", "/", http.StatusOK, "api"},
+		{"api.example.com", "/missing", http.StatusNotFound, "api: no route"},
+		{"acme.example.com", "/users/42", http.StatusOK, "acme/42"},
+		{"localhost:8080", "/", http.StatusOK, "default"},
+		{"a.b.example.com", "/users/42", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Host = c.host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.status || (c.body != "" && w.Body.String() != c.body) {
+			t.Fatalf("%s%s: expected %d %q, got %d %q", c.host, c.path, c.status, c.body, w.Code, w.Body.String())
+		}
+		if (w.Header().Get("X-Host") == "api") != strings.HasPrefix(strings.ToLower(c.host), "api.") {
+			t.Fatalf("%s%s: host middleware should only run for api.example.com", c.host, c.path)
+		}
+	}
+
+	// 主机上没有的路由不会回退到 engine 的路由
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "acme.example.com"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != MIMEProblemJSON {
+		t.Fatalf("expected default 404 for tenant host, got %d %s", w.Code, w.Body.String())
+	}
+
+	if !strings.Contains(r.RouteTree(), "host :tenant.example.com") {
+		t.Fatalf("route tree should include hosts:\n%s", r.RouteTree())
+	}
+}
@@ -1,14 +1,185 @@
 package gee
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// 访问日志的输出格式
+const (
+	LogFormatText   = "text"   // [200] /hello in 1ms, 12 bytes，与 Logger() 一致
+	LogFormatJSON   = "json"   // 每行一个 JSON 对象
+	LogFormatLogfmt = "logfmt" // 每行一组 key=value
+)
+
+/**
+ * 访问日志中间件的配置
+ * Format 为空时使用 text 格式；Output 为空时 text 格式写入标准库 log，其他格式写入 os.Stderr
+ * SkipPaths 中的请求不记录，可以是具体路径（/healthz）或路由 pattern（/static/*filepath）
+ * SampleRate 在 (0, 1) 之间时按比例采样，状态码 >= 500 的请求总是记录，为 0 或 >= 1 时全部记录
+ */
+type LoggerConfig struct {
+	Format     string
+	Output     io.Writer
+	SkipPaths  []string
+	SampleRate float64
+}
+
+// 一条日志中的字段，按添加顺序输出
+type logField struct {
+	key   string
+	value interface{}
+}
+
+/**
+ * 日志的输出端，访问日志和 Recovery 的 panic 日志共用同一个输出端
+ * LoggerWithConfig 会把它保存在 c.logSink 上，内层的 Recovery 据此输出结构化的 panic 日志
+ */
+type logSink struct {
+	format string
+	out    io.Writer // 为 nil 时写入标准库 log
+	mu     sync.Mutex
+}
+
+func (s *logSink) write(level string, fields []logField, text string) {
+	var line string
+	switch s.format {
+	case LogFormatJSON:
+		line = encodeJSONLog(level, fields)
+	case LogFormatLogfmt:
+		line = encodeLogfmt(level, fields)
+	default:
+		if s.out == nil {
+			log.Print(text)
+			return
+		}
+		line = time.Now().Format("2006/01/02 15:04:05 ") + text
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	io.WriteString(s.out, line)
+}
+
+func encodeJSONLog(level string, fields []logField) string {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSONValue(&b, time.Now().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(&b, level)
+	for _, f := range fields {
+		b.WriteString(",")
+		writeJSONValue(&b, f.key)
+		b.WriteString(":")
+		writeJSONValue(&b, f.value)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func writeJSONValue(b *strings.Builder, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+func encodeLogfmt(level string, fields []logField) string {
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().Format(time.RFC3339Nano) + " level=" + level)
+	for _, f := range fields {
+		b.WriteString(" " + f.key + "=")
+		var s string
+		switch v := f.value.(type) {
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		if s == "" || strings.ContainsAny(s, " =\"\t\r\n\\") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
 func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// 以 format 格式把访问日志写入 out 的便捷方法
+func LoggerWithWriter(out io.Writer, format string) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{Output: out, Format: format})
+}
+
+func LoggerWithConfig(cfg LoggerConfig) HandlerFunc {
+	sink := &logSink{format: cfg.Format, out: cfg.Output}
+	if sink.out == nil && sink.format != "" && sink.format != LogFormatText {
+		sink.out = os.Stderr
+	}
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip[p] = true
+	}
+
 	return func(c *Context) {
+		c.logSink = sink
 		t := time.Now()
 		c.Next() // 在 handler 之后执行
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		latency := time.Since(t)
+
+		if skip[c.Path] || (c.fullPath != "" && skip[c.fullPath]) {
+			return
+		}
+		status := c.Writer.Status()
+		if cfg.SampleRate > 0 && cfg.SampleRate < 1 && status < 500 && rand.Float64() >= cfg.SampleRate {
+			return
+		}
+
+		size := c.Writer.Size()
+		if size < 0 { // 只设置了状态码，还没有写入 body
+			size = 0
+		}
+		fields := []logField{
+			{"method", c.Method},
+			{"path", c.Path},
+			{"route", c.fullPath},
+			{"status", status},
+			{"bytes", size},
+			{"latency_ms", float64(latency.Microseconds()) / 1000},
+			{"client_ip", c.ClientIP()},
+			{"request_id", requestID(c)},
+			{"user_agent", c.Req.UserAgent()},
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, logField{"errors", c.Errors.Error()})
+		}
+		level := "info"
+		if status >= 500 {
+			level = "error"
+		}
+		sink.write(level, fields, fmt.Sprintf("[%d] %s in %v, %d bytes", status, c.Req.RequestURI, latency, size))
+	}
+}
+
+// 请求 ID 优先取响应头（由 RequestID 中间件生成），其次是请求头
+func requestID(c *Context) string {
+	if id := c.Writer.Header().Get("X-Request-ID"); id != "" {
+		return id
 	}
+	return c.Req.Header.Get("X-Request-ID")
 }
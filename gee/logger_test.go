@@ -0,0 +1,84 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	r := New()
+	r.Use(LoggerWithConfig(LoggerConfig{Format: LogFormatJSON, Output: &out, SkipPaths: []string{"/healthz"}}), Recovery())
+	r.GET("/users/:id", func(c *Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	r.GET("/healthz", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "gee-test")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records (access, panic, access), got %d:\n%s", len(lines), out.String())
+	}
+	var access map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &access); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"level": "info", "method": "GET", "path": "/users/42", "route": "/users/:id",
+		"status": 200.0, "bytes": 7.0, "client_ip": "192.0.2.1", "request_id": "req-1", "user_agent": "gee-test",
+	}
+	for k, v := range expect {
+		if access[k] != v {
+			t.Fatalf("%s: expected %v, got %v", k, v, access[k])
+		}
+	}
+	if _, ok := access["latency_ms"]; !ok {
+		t.Fatal("latency_ms is missing")
+	}
+
+	var panicRecord map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &panicRecord); err != nil {
+		t.Fatal(err)
+	}
+	if panicRecord["level"] != "error" || panicRecord["panic"] != "boom" ||
+		!strings.Contains(panicRecord["trace"].(string), "Traceback:") {
+		t.Fatalf("unexpected panic record: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], `"status":500`) {
+		t.Fatalf("expected 500 access record, got %s", lines[2])
+	}
+}
+
+func TestLoggerLogfmt(t *testing.T) {
+	var out bytes.Buffer
+	r := New()
+	r.Use(LoggerWithWriter(&out, LogFormatLogfmt))
+	r.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hi")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("User-Agent", "curl/8.0 (x86_64)")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := out.String()
+	for _, s := range []string{"level=info", "method=GET", "route=/hello", "status=200", "bytes=2", `user_agent="curl/8.0 (x86_64)"`, `request_id=""`} {
+		if !strings.Contains(line, s) {
+			t.Fatalf("expected %q in %q", s, line)
+		}
+	}
+}
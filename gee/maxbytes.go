@@ -0,0 +1,14 @@
+//go:build go1.19
+
+package gee
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 请求体超出了 http.MaxBytesReader 的限制
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
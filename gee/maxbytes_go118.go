@@ -0,0 +1,10 @@
+//go:build !go1.19
+
+package gee
+
+import "strings"
+
+// Go 1.19 之前 http.MaxBytesReader 只返回一个普通的错误，只能比较错误信息
+func isBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
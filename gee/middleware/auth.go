@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"gee"
+)
+
+// 认证通过的主体在 c.Keys 中的 key
+const PrincipalKey = "Principal"
+
+/**
+ * 认证通过的主体，由 BasicAuth、APIKey、JWT 中间件设置，handler 通过 GetPrincipal 读取
+ * Subject 是用户名、API Key 对应的名字或 JWT 的 sub；Claims 只有 JWT 认证时才有
+ */
+type Principal struct {
+	Subject string
+	Method  string // basic、apikey 或 jwt
+	Claims  Claims
+}
+
+// 返回认证通过的主体，没有经过认证中间件时 ok 为 false
+func GetPrincipal(c *gee.Context) (*Principal, bool) {
+	return gee.GetAs[*Principal](c, PrincipalKey)
+}
+
+// 比较 a 和 b，耗时与内容和长度都无关
+func secureCompare(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func unauthorized(c *gee.Context, challenge, message string) {
+	c.SetHeader("WWW-Authenticate", challenge)
+	c.Fail(http.StatusUnauthorized, message)
+}
+
+// 用户名 => 密码
+type Accounts map[string]string
+
+/**
+ * HTTP Basic 认证的配置
+ * Validate 不为空时使用它校验用户名和密码，例如查询数据库；否则使用 Accounts
+ */
+type BasicAuthConfig struct {
+	Accounts Accounts
+	Validate func(c *gee.Context, user, password string) bool
+	// 默认为 Authorization Required
+	Realm string
+}
+
+func BasicAuth(accounts Accounts) gee.HandlerFunc {
+	return BasicAuthWithConfig(BasicAuthConfig{Accounts: accounts})
+}
+
+/**
+ * 校验 HTTP Basic 认证，失败时返回 401 并带上 WWW-Authenticate，浏览器会弹出登录框
+ * 使用 Accounts 时，用户不存在也会做一次同样耗时的比较，避免通过响应时间推测用户名
+ */
+func BasicAuthWithConfig(cfg BasicAuthConfig) gee.HandlerFunc {
+	if cfg.Realm == "" {
+		cfg.Realm = "Authorization Required"
+	}
+	if cfg.Validate == nil {
+		accounts := cfg.Accounts
+		cfg.Validate = func(c *gee.Context, user, password string) bool {
+			expected, ok := accounts[user]
+			// 先比较再判断用户是否存在，两种情况的耗时相同
+			return secureCompare(password, expected) && ok
+		}
+	}
+	challenge := "Basic realm=" + strconv.Quote(cfg.Realm)
+	return func(c *gee.Context) {
+		user, password, ok := c.Req.BasicAuth()
+		if !ok || !cfg.Validate(c, user, password) {
+			unauthorized(c, challenge, "invalid credentials")
+			return
+		}
+		c.Set(PrincipalKey, &Principal{Subject: user, Method: "basic"})
+		c.Next()
+	}
+}
+
+/**
+ * API Key 认证的配置，依次从请求头 Header 和查询参数 Query 中读取
+ * Keys 是 API Key => 名字，名字作为 Principal.Subject；Validate 不为空时使用它查找
+ */
+type APIKeyConfig struct {
+	Keys     map[string]string
+	Validate func(c *gee.Context, key string) (subject string, ok bool)
+	// 默认为 X-API-Key
+	Header string
+	// 查询参数名，为空时不从查询参数读取；查询参数容易出现在日志中，尽量使用请求头
+	Query string
+}
+
+func APIKey(keys map[string]string) gee.HandlerFunc {
+	return APIKeyWithConfig(APIKeyConfig{Keys: keys})
+}
+
+// 缺少或无效的 API Key 返回 401
+func APIKeyWithConfig(cfg APIKeyConfig) gee.HandlerFunc {
+	if cfg.Header == "" {
+		cfg.Header = "X-API-Key"
+	}
+	if cfg.Validate == nil {
+		keys := cfg.Keys
+		cfg.Validate = func(c *gee.Context, key string) (string, bool) {
+			// 逐个比较所有的 key，而不是查找 map，耗时与哪个 key 匹配无关
+			subject, found := "", false
+			for k, name := range keys {
+				if secureCompare(key, k) {
+					subject, found = name, true
+				}
+			}
+			return subject, found
+		}
+	}
+	challenge := "APIKey header=" + strconv.Quote(cfg.Header)
+	return func(c *gee.Context) {
+		key := c.Req.Header.Get(cfg.Header)
+		if key == "" && cfg.Query != "" {
+			key = c.Query(cfg.Query)
+		}
+		if key == "" {
+			unauthorized(c, challenge, "missing API key")
+			return
+		}
+		subject, ok := cfg.Validate(c, key)
+		if !ok {
+			unauthorized(c, challenge, "invalid API key")
+			return
+		}
+		c.Set(PrincipalKey, &Principal{Subject: subject, Method: "apikey"})
+		c.Next()
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gee"
+)
+
+/**
+ * 限制请求 body 的大小，单位为字节
+ * Content-Length 已经超出时直接交给 ErrorHandler 返回 413 Request Entity Too Large
+ * 否则（包括 chunked 请求）读取超过 n 字节时返回 *http.MaxBytesError，c.Bind 系列方法会据此返回 413
+ */
+func BodyLimit(n int64) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		if c.Req.ContentLength > n {
+			c.Error(gee.NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large"))
+			c.Abort()
+			return
+		}
+		if c.Req.Body != nil && c.Req.Body != http.NoBody {
+			c.Req.Body = http.MaxBytesReader(c.Writer, c.Req.Body, n)
+		}
+		c.Next()
+	}
+}
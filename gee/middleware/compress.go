@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gee"
+)
+
+/**
+ * 响应压缩的配置
+ * Level 为 compress/flate 的压缩级别，为 0 时使用 flate.DefaultCompression
+ * MinLength 为第一次写入的最小长度，更短的响应不压缩；响应头中有 Content-Length 时以它为准
+ * ExcludedContentTypes 中的类型不压缩，默认排除图片、音视频和常见的压缩包
+ */
+type CompressConfig struct {
+	Level                int
+	MinLength            int
+	ExcludedContentTypes []string
+}
+
+var defaultExcludedContentTypes = []string{
+	"image/", "audio/", "video/",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-brotli",
+	"application/octet-stream", "text/event-stream",
+}
+
+// 根据 Accept-Encoding 使用 gzip 或 deflate 压缩响应
+func Gzip() gee.HandlerFunc {
+	return Compress(CompressConfig{})
+}
+
+/**
+ * 压缩与否在第一次写入 body 时才决定，此时 handler 已经设置好了状态码和响应头：
+ *   - 已经设置了 Content-Encoding 的响应（例如 StaticConfig.Precompressed）原样输出
+ *   - 204、304、206 以及 HEAD 请求不压缩
+ *   - WebSocket 等协议升级请求不压缩
+ */
+func Compress(cfg CompressConfig) gee.HandlerFunc {
+	if cfg.Level == 0 {
+		cfg.Level = flate.DefaultCompression
+	}
+	if cfg.ExcludedContentTypes == nil {
+		cfg.ExcludedContentTypes = defaultExcludedContentTypes
+	}
+	gzipPool := sync.Pool{New: func() interface{} {
+		w, err := gzip.NewWriterLevel(io.Discard, cfg.Level)
+		if err != nil {
+			panic(err)
+		}
+		return w
+	}}
+	flatePool := sync.Pool{New: func() interface{} {
+		w, err := flate.NewWriter(io.Discard, cfg.Level)
+		if err != nil {
+			panic(err)
+		}
+		return w
+	}}
+
+	return func(c *gee.Context) {
+		encoding := negotiateEncoding(c.Req.Header.Get("Accept-Encoding"))
+		if encoding == "" || c.Method == http.MethodHead || c.Req.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: &cfg, encoding: encoding}
+		switch encoding {
+		case "gzip":
+			w.newEncoder = func(out io.Writer) compressor {
+				gz := gzipPool.Get().(*gzip.Writer)
+				gz.Reset(out)
+				return gz
+			}
+			w.release = func(e compressor) { gzipPool.Put(e) }
+		case "deflate":
+			w.newEncoder = func(out io.Writer) compressor {
+				fw := flatePool.Get().(*flate.Writer)
+				fw.Reset(out)
+				return fw
+			}
+			w.release = func(e compressor) { flatePool.Put(e) }
+		}
+		// 无论是否压缩，响应都随 Accept-Encoding 变化
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		c.Writer = w
+		defer func() {
+			w.close()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+type compressor interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+/**
+ * 包装 gee.ResponseWriter，第一次写入时决定是否压缩
+ * 决定压缩后删除 Content-Length，数据经过 encoder 写入底层的 ResponseWriter
+ */
+type compressWriter struct {
+	gee.ResponseWriter
+	cfg        *CompressConfig
+	encoding   string
+	newEncoder func(io.Writer) compressor
+	release    func(compressor)
+
+	decided bool
+	encoder compressor // 为 nil 时不压缩
+}
+
+func (w *compressWriter) decide(first []byte) {
+	w.decided = true
+	header := w.Header()
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent || status < 200 {
+		return
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(first)
+		header.Set("Content-Type", contentType)
+	}
+	for _, excluded := range w.cfg.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return
+		}
+	}
+	length := len(first)
+	if cl, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+		length = cl
+	}
+	if length < w.cfg.MinLength {
+		return
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	w.encoder = w.newEncoder(w.ResponseWriter)
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decide(data)
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	// 先发出响应头，确保 Status() 等信息与未压缩时一致
+	w.ResponseWriter.WriteHeaderNow()
+	return w.encoder.Write(data)
+}
+
+// 流式响应（c.Stream、c.SSEvent）需要把 encoder 中缓冲的数据一起刷出
+func (w *compressWriter) Flush() {
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) close() {
+	if w.encoder == nil {
+		return
+	}
+	w.encoder.Close()
+	w.release(w.encoder)
+	w.encoder = nil
+}
+
+// 按 Accept-Encoding 的权重选择 gzip 或 deflate，权重相同时优先 gzip，都不支持时返回 ""
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if name != "gzip" && name != "deflate" {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gee"
+)
+
+/**
+ * 跨域资源共享（CORS）的配置
+ * AllowOrigins 可以是 "*"、具体的 origin（https://example.com），或者通配子域名（https://*.example.com）
+ * AllowOriginFunc 不为空时优先使用它判断 origin 是否允许
+ * AllowHeaders 为空时原样允许预检请求中 Access-Control-Request-Headers 列出的请求头
+ * AllowCredentials 为 true 时不能返回 "*"，会改为回显请求的 origin
+ */
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowOriginFunc  func(origin string) bool
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// 允许任意 origin 的 CORS 中间件，不携带凭证
+func CORS() gee.HandlerFunc {
+	return CORSWithConfig(CORSConfig{AllowOrigins: []string{"*"}})
+}
+
+/**
+ * 预检请求（带有 Access-Control-Request-Method 的 OPTIONS 请求）在这里直接以 204 结束，不会执行 handler
+ * origin 不被允许时，预检请求返回 403，普通请求照常执行但不带 CORS 响应头，由浏览器拦截
+ */
+func CORSWithConfig(cfg CORSConfig) gee.HandlerFunc {
+	if len(cfg.AllowMethods) == 0 {
+		cfg.AllowMethods = defaultCORSMethods
+	}
+	allowAll := false
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+	}
+	allowMethods := strings.Join(cfg.AllowMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	}
+
+	allowed := func(origin string) bool {
+		if cfg.AllowOriginFunc != nil {
+			return cfg.AllowOriginFunc(origin)
+		}
+		if allowAll {
+			return true
+		}
+		for _, o := range cfg.AllowOrigins {
+			if matchOrigin(o, origin) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(c *gee.Context) {
+		origin := c.Req.Header.Get("Origin")
+		if origin == "" { // 不是跨域请求
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+		if !allowAll || cfg.AllowCredentials || cfg.AllowOriginFunc != nil {
+			// 响应随 origin 变化，缓存时需要区分
+			header.Add("Vary", "Origin")
+		}
+		if !allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if allowAll && !cfg.AllowCredentials && cfg.AllowOriginFunc == nil {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.Req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// pattern 中的 * 只能出现在 scheme:// 之后，匹配一级或多级子域名
+func matchOrigin(pattern, origin string) bool {
+	if !strings.Contains(pattern, "*") {
+		return strings.EqualFold(pattern, origin)
+	}
+	i := strings.Index(pattern, "*")
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+		strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix))
+}
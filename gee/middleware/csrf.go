@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+
+	"gee"
+	"gee/sessions"
+)
+
+// CSRF 中间件的状态在 c.Keys 中的 key
+const CSRFKey = "CSRF"
+
+const csrfTokenLength = 32
+
+/**
+ * CSRF 防护的配置
+ * 默认使用 double-submit cookie：令牌保存在 cookie 中，提交的表单字段或请求头必须与之一致
+ * Session 为 true 时改为 synchronizer token：令牌保存在会话中，需要先使用 sessions.Sessions
+ */
+type CSRFConfig struct {
+	// 表单字段名，默认为 csrf_token
+	FieldName string
+	// 请求头名，默认为 X-CSRF-Token，用于 AJAX 请求
+	// 用 c.MultipartReader 流式读取的上传请求必须通过请求头提交令牌，读取表单字段会消耗请求体
+	HeaderName string
+	// double-submit 模式下保存令牌的 cookie 名，默认为 _csrf
+	CookieName string
+	// double-submit 模式下 cookie 的选项
+	Cookie gee.CookieOptions
+	// 把令牌保存在会话中
+	Session bool
+}
+
+// 一个请求中的 CSRF 令牌
+type csrfState struct {
+	token []byte
+	field string
+}
+
+func CSRF() gee.HandlerFunc {
+	return CSRFWithConfig(CSRFConfig{})
+}
+
+/**
+ * 除 GET、HEAD、OPTIONS、TRACE 之外的请求都必须带上令牌，否则返回 403
+ * 页面中通过 CSRFToken(c) 或模板函数 csrfField 输出令牌，
+ * 每次输出的值都经过随机掩码处理，防止 BREACH 这类针对压缩的攻击推测出令牌
+ * 请求头中没有令牌时通过 c.PostForm 读取表单字段，multipart 表单按 engine.Upload 的限制解析
+ */
+func CSRFWithConfig(cfg CSRFConfig) gee.HandlerFunc {
+	if cfg.FieldName == "" {
+		cfg.FieldName = "csrf_token"
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = "X-CSRF-Token"
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "_csrf"
+	}
+	return func(c *gee.Context) {
+		token := loadCSRFToken(c, &cfg)
+		if token == nil {
+			token = make([]byte, csrfTokenLength)
+			if _, err := rand.Read(token); err != nil {
+				c.Error(err)
+				c.Fail(http.StatusInternalServerError, "cannot generate CSRF token")
+				return
+			}
+			encoded := base64.RawURLEncoding.EncodeToString(token)
+			if cfg.Session {
+				sessions.Default(c).Set(cfg.FieldName, encoded)
+			} else {
+				c.SetCookie(cfg.CookieName, encoded, cfg.Cookie)
+			}
+		}
+		c.Set(CSRFKey, &csrfState{token: token, field: cfg.FieldName})
+
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			submitted := c.Req.Header.Get(cfg.HeaderName)
+			if submitted == "" {
+				submitted = c.PostForm(cfg.FieldName)
+			}
+			if !validCSRFToken(token, submitted) {
+				c.Fail(http.StatusForbidden, "invalid CSRF token")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// 读取已经保存的令牌，不存在或格式错误时返回 nil
+func loadCSRFToken(c *gee.Context, cfg *CSRFConfig) []byte {
+	var encoded string
+	if cfg.Session {
+		encoded = sessions.Default(c).GetString(cfg.FieldName)
+	} else {
+		encoded, _ = c.Cookie(cfg.CookieName)
+	}
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(token) != csrfTokenLength {
+		return nil
+	}
+	return token
+}
+
+// 提交的令牌是 掩码 + (掩码 XOR 令牌)，还原后与保存的令牌比较
+func validCSRFToken(token []byte, submitted string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(submitted)
+	if err != nil || len(b) != 2*csrfTokenLength {
+		return false
+	}
+	mask, masked := b[:csrfTokenLength], b[csrfTokenLength:]
+	unmasked := make([]byte, csrfTokenLength)
+	for i := range unmasked {
+		unmasked[i] = mask[i] ^ masked[i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
+
+// 返回放在表单字段或请求头中的令牌，每次调用的结果都不同；没有使用 CSRF 中间件时返回 ""
+func CSRFToken(c *gee.Context) string {
+	state, ok := gee.GetAs[*csrfState](c, CSRFKey)
+	if !ok {
+		return ""
+	}
+	b := make([]byte, 2*csrfTokenLength)
+	if _, err := rand.Read(b[:csrfTokenLength]); err != nil {
+		return ""
+	}
+	for i := 0; i < csrfTokenLength; i++ {
+		b[csrfTokenLength+i] = b[i] ^ state.token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// 返回包含令牌的隐藏表单字段
+func CSRFField(c *gee.Context) template.HTML {
+	state, ok := gee.GetAs[*csrfState](c, CSRFKey)
+	if !ok {
+		return ""
+	}
+	return csrfField(state.field, CSRFToken(c))
+}
+
+func csrfField(name, token string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(name) +
+		`" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+/**
+ * 模板函数 csrfField，输出包含令牌的隐藏表单字段，参数可以是 *gee.Context 或者 CSRFToken 返回的令牌，例如
+ * engine.SetFuncMap(middleware.CSRFTemplateFuncs())
+ * c.HTML(http.StatusOK, "form.tmpl", gee.H{"ctx": c})
+ * <form method="post">{{csrfField .ctx}}</form>
+ * 传入令牌时使用默认的字段名 csrf_token；已经有其他模板函数时，把这里的函数合并进去即可
+ */
+func CSRFTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func(v interface{}) template.HTML {
+			switch v := v.(type) {
+			case *gee.Context:
+				return CSRFField(v)
+			case string:
+				return csrfField("csrf_token", v)
+			}
+			return ""
+		},
+	}
+}
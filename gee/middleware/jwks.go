@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// JWK 中的二进制字段使用不带填充的 base64url 编码
+var jwtEncoding = base64.RawURLEncoding
+
+// JWKS 文件中的一个密钥，只支持 RSA 公钥和对称密钥（oct）
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// oct
+	K string `json:"k,omitempty"`
+}
+
+/**
+ * 从本地 JSON Web Key Set 文件加载的密钥，实现了 KeySet，例如
+ * keys, err := middleware.LoadJWKS("/etc/app/jwks.json")
+ * r.Use(middleware.JWTWithConfig(middleware.JWTConfig{KeySet: keys}))
+ *
+ * 轮换密钥时把新密钥加入文件并开始用它签发，旧密钥保留到它签发的令牌都过期后再删除
+ * 文件的修改时间每分钟（SetRefreshInterval 可以修改）检查一次，遇到未知的 kid 时立即检查（最多每秒一次），
+ * 文件变化后重新加载；重新加载失败时继续使用之前的密钥
+ */
+type JWKS struct {
+	path string
+
+	mu        sync.RWMutex
+	interval  time.Duration // 检查文件是否变化的间隔
+	keys      map[string]interface{}
+	modTime   time.Time
+	lastCheck time.Time
+	now       func() time.Time
+}
+
+func LoadJWKS(path string) (*JWKS, error) {
+	s := &JWKS{path: path, interval: time.Minute, now: time.Now}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := readJWKS(path)
+	if err != nil {
+		return nil, err
+	}
+	s.keys, s.modTime, s.lastCheck = keys, info.ModTime(), s.now()
+	return s, nil
+}
+
+func readJWKS(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %s: %w", path, err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: %s: key %q: %w", path, k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := jwtEncoding.DecodeString(k.N)
+		e, err2 := jwtEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "oct":
+		key, err := jwtEncoding.DecodeString(k.K)
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("invalid symmetric key")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// 返回 kid 对应的密钥，令牌没有 kid 且文件中只有一个密钥时返回该密钥
+func (s *JWKS) Key(kid string) (interface{}, error) {
+	s.refresh(false)
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	// 可能是刚轮换的新密钥
+	if s.refresh(true) {
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+}
+
+func (s *JWKS) lookup(kid string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// 设置检查文件是否变化的间隔，默认为 1 分钟
+func (s *JWKS) SetRefreshInterval(d time.Duration) {
+	s.mu.Lock()
+	s.interval = d
+	s.mu.Unlock()
+}
+
+/**
+ * 文件变化时重新加载，返回是否加载了新的密钥；force 为 true 时检查间隔最多为 1 秒
+ * 绝大多数调用都还没到检查时间，只需要读锁，不会让并发的令牌校验互相等待
+ */
+func (s *JWKS) refresh(force bool) bool {
+	now := s.now()
+	s.mu.RLock()
+	due := s.due(now, force)
+	s.mu.RUnlock()
+	if !due {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 等待写锁期间可能已经有其他请求检查过了
+	if !s.due(now, force) {
+		return false
+	}
+	s.lastCheck = now
+	info, err := os.Stat(s.path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return false
+	}
+	keys, err := readJWKS(s.path)
+	if err != nil {
+		log.Printf("[WARNING] %v, keep using the previous keys", err)
+		return false
+	}
+	s.keys, s.modTime = keys, info.ModTime()
+	return true
+}
+
+// 调用时需要持有 s.mu
+func (s *JWKS) due(now time.Time, force bool) bool {
+	interval := s.interval
+	if force && interval > time.Second {
+		interval = time.Second
+	}
+	return now.Sub(s.lastCheck) >= interval
+}
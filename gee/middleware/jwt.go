@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gee"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 令牌的解析和签名由 golang-jwt 完成，这些错误可以直接与 jwt 包中的错误比较
+var (
+	ErrTokenMissing   = errors.New("jwt: token is missing")
+	ErrTokenMalformed = jwt.ErrTokenMalformed
+	// 签名不正确，或者算法与密钥不匹配
+	ErrTokenSignature = jwt.ErrTokenSignatureInvalid
+	ErrTokenExpired   = jwt.ErrTokenExpired
+	ErrTokenNotActive = jwt.ErrTokenNotValidYet
+	ErrTokenIssuer    = jwt.ErrTokenInvalidIssuer
+	ErrTokenAudience  = jwt.ErrTokenInvalidAudience
+	// 找不到 kid 对应的密钥
+	ErrUnknownKey = errors.New("jwt: unknown signing key")
+)
+
+// JWT 的 payload，数字经过 JSON 解码后是 float64
+type Claims map[string]interface{}
+
+func (claims Claims) String(key string) string {
+	s, _ := claims[key].(string)
+	return s
+}
+
+func (claims Claims) Subject() string {
+	return claims.String("sub")
+}
+
+// 读取 exp、nbf、iat 这类 NumericDate，不存在时 ok 为 false
+func (claims Claims) Time(key string) (t time.Time, ok bool) {
+	switch v := claims[key].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	}
+	return time.Time{}, false
+}
+
+// aud 可以是字符串或字符串数组
+func (claims Claims) HasAudience(audience string) bool {
+	return jwt.MapClaims(claims).VerifyAudience(audience, true)
+}
+
+// 按 kid 查找验证签名的密钥，例如 JWKS；密钥是 HMAC 的 []byte 或者 *rsa.PublicKey
+type KeySet interface {
+	Key(kid string) (interface{}, error)
+}
+
+// 没有配置 Algorithms 时允许的算法
+var jwtAlgorithms = []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}
+
+/**
+ * JWT 认证的配置，Key 和 KeySet 二选一
+ * 密钥的类型决定了可以使用的算法：[]byte 只接受 HS256/384/512，*rsa.PublicKey 只接受 RS256/384/512，
+ * 因此不会出现用 RSA 公钥当作 HMAC 密钥验证的算法混淆攻击；alg 为 none 的令牌总是被拒绝
+ */
+type JWTConfig struct {
+	Key    interface{}
+	KeySet KeySet
+	// 允许的算法，为空时允许 HS256/384/512 和 RS256/384/512
+	Algorithms []string
+	// 不为空时校验 iss 和 aud
+	Issuer   string
+	Audience string
+	// 校验 exp、nbf 时允许的时钟误差
+	Leeway time.Duration
+	// 为 true 时允许没有 exp 的令牌，默认必须有 exp
+	AllowNoExpiry bool
+	// 获取令牌，默认从 Authorization: Bearer <token> 中读取
+	TokenFunc func(c *gee.Context) string
+
+	now func() time.Time
+}
+
+func JWT(secret []byte) gee.HandlerFunc {
+	return JWTWithConfig(JWTConfig{Key: secret})
+}
+
+/**
+ * 校验 JWT 的签名和 exp、nbf、iss、aud，通过后把 Principal（Claims 为令牌的 payload）保存到 c.Keys
+ * 失败时返回 401，WWW-Authenticate 中带有 RFC 6750 定义的错误信息，具体原因记录在 c.Errors 中
+ */
+func JWTWithConfig(cfg JWTConfig) gee.HandlerFunc {
+	if cfg.Key == nil && cfg.KeySet == nil {
+		panic("gee: JWT requires a Key or a KeySet")
+	}
+	if cfg.TokenFunc == nil {
+		cfg.TokenFunc = bearerToken
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = jwtAlgorithms
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	return func(c *gee.Context) {
+		token := cfg.TokenFunc(c)
+		if token == "" {
+			unauthorized(c, `Bearer`, ErrTokenMissing.Error())
+			return
+		}
+		claims, err := cfg.parse(token)
+		if err != nil {
+			c.Error(err)
+			unauthorized(c, `Bearer error="invalid_token", error_description=`+strconv.Quote(err.Error()), "invalid token")
+			return
+		}
+		c.Set(PrincipalKey, &Principal{Subject: claims.Subject(), Method: "jwt", Claims: claims})
+		c.Next()
+	}
+}
+
+func bearerToken(c *gee.Context) string {
+	auth := c.Req.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// 签名由 jwt 包校验，之后按 cfg.now 和 Leeway 校验 exp、nbf、iss、aud
+func (cfg *JWTConfig) parse(token string) (Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(cfg.Algorithms), jwt.WithoutClaimsValidation())
+	parsed, err := parser.ParseWithClaims(token, jwt.MapClaims{}, cfg.key)
+	if err != nil {
+		return nil, err
+	}
+	claims := parsed.Claims.(jwt.MapClaims)
+
+	now := cfg.now()
+	if !claims.VerifyExpiresAt(now.Add(-cfg.Leeway).Unix(), false) {
+		return nil, ErrTokenExpired
+	}
+	if _, ok := claims["exp"]; !ok && !cfg.AllowNoExpiry {
+		return nil, fmt.Errorf("%w: exp is required", ErrTokenExpired)
+	}
+	if !claims.VerifyNotBefore(now.Add(cfg.Leeway).Unix(), false) {
+		return nil, ErrTokenNotActive
+	}
+	if cfg.Issuer != "" && !claims.VerifyIssuer(cfg.Issuer, true) {
+		return nil, ErrTokenIssuer
+	}
+	if cfg.Audience != "" && !claims.VerifyAudience(cfg.Audience, true) {
+		return nil, ErrTokenAudience
+	}
+	return Claims(claims), nil
+}
+
+// jwt 包的 Keyfunc，配置了 KeySet 时按 header 中的 kid 查找密钥
+func (cfg *JWTConfig) key(token *jwt.Token) (interface{}, error) {
+	if cfg.KeySet == nil {
+		return cfg.Key, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	return cfg.KeySet.Key(kid)
+}
+
+/**
+ * 签发 JWT，key 是 HMAC 的 []byte 或者 *rsa.PrivateKey，kid 为空时不写入 header，例如
+ * token, err := middleware.SignJWT("HS256", secret, "", middleware.Claims{
+ *     "sub": "tom",
+ *     "exp": time.Now().Add(time.Hour).Unix(),
+ * })
+ */
+func SignJWT(alg string, key interface{}, kid string, claims Claims) (string, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || method == jwt.SigningMethodNone {
+		return "", fmt.Errorf("jwt: unsupported algorithm %q", alg)
+	}
+	token := jwt.NewWithClaims(method, jwt.MapClaims(claims))
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	return token.SignedString(key)
+}
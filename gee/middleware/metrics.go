@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gee"
+)
+
+// 与 Prometheus 客户端相同的默认直方图桶，单位为秒
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// 没有匹配到路由的请求使用的 route 标签，避免按原始路径产生无限多的序列
+const unmatchedRoute = "unmatched"
+
+// 非标准的请求方法使用的 method 标签，方法名来自客户端，同样不能直接作为标签
+const otherMethod = "other"
+
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+type MetricsConfig struct {
+	// 指标名的前缀，默认为 gee，例如 gee_http_requests_total
+	Namespace string
+	// 请求耗时直方图的桶上限（秒），默认为 DefaultBuckets
+	Buckets []float64
+	// ServeMetrics 注册的路径，默认为 /metrics
+	Path string
+}
+
+type seriesKey struct {
+	method, route, status string
+}
+
+type histogram struct {
+	counts []uint64 // 每个桶各自的计数，输出时再累加
+	sum    float64
+	count  uint64
+}
+
+/**
+ * 进程内的 HTTP 指标，以 Prometheus 文本格式输出，不依赖外部服务：
+ *   <ns>_http_requests_total              counter，标签 method、route、status
+ *   <ns>_http_request_duration_seconds    histogram，标签 method、route、status
+ *   <ns>_http_requests_in_flight          gauge，标签 method、route
+ * route 是路由的 pattern（c.FullPath()），例如 /users/:id，而不是原始路径
+ */
+type Metrics struct {
+	cfg MetricsConfig
+
+	mu        sync.Mutex
+	requests  map[seriesKey]uint64
+	durations map[seriesKey]*histogram
+	inFlight  map[seriesKey]int64
+	now       func() time.Time
+}
+
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "gee"
+	}
+	if len(cfg.Buckets) == 0 {
+		cfg.Buckets = DefaultBuckets
+	}
+	cfg.Buckets = append([]float64(nil), cfg.Buckets...)
+	sort.Float64s(cfg.Buckets)
+	if cfg.Path == "" {
+		cfg.Path = "/metrics"
+	}
+	return &Metrics{
+		cfg:       cfg,
+		requests:  make(map[seriesKey]uint64),
+		durations: make(map[seriesKey]*histogram),
+		inFlight:  make(map[seriesKey]int64),
+		now:       time.Now,
+	}
+}
+
+/**
+ * 创建 Metrics，把记录指标的中间件注册为全局中间件，并在 cfg.Path 上输出指标，例如
+ * r := gee.New()
+ * middleware.ServeMetrics(r, middleware.MetricsConfig{})
+ * r.GET("/users/:id", showUser)
+ * 中间件链在注册路由时就已经确定，因此要在注册其他路由之前调用
+ */
+func ServeMetrics(engine *gee.Engine, cfg MetricsConfig) *Metrics {
+	m := NewMetrics(cfg)
+	engine.Use(m.Middleware())
+	engine.GET(m.cfg.Path, m.Handler())
+	return m
+}
+
+/**
+ * 记录请求指标的中间件
+ * handler panic 时同样减少 in-flight，并按 500 记录这次请求，然后继续向外 panic 交给 Recovery
+ */
+func (m *Metrics) Middleware() gee.HandlerFunc {
+	return func(c *gee.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := methodLabel(c.Method)
+		flight := seriesKey{method: method, route: route}
+		m.mu.Lock()
+		m.inFlight[flight]++
+		m.mu.Unlock()
+		start := m.now()
+		defer func() {
+			status := c.Writer.Status()
+			err := recover()
+			if err != nil {
+				status = http.StatusInternalServerError
+			}
+			m.mu.Lock()
+			m.inFlight[flight]--
+			m.mu.Unlock()
+			m.observe(seriesKey{method: method, route: route, status: strconv.Itoa(status)}, m.now().Sub(start))
+			if err != nil {
+				panic(err)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+func (m *Metrics) observe(key seriesKey, d time.Duration) {
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[key]++
+	h := m.durations[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.cfg.Buckets))}
+		m.durations[key] = h
+	}
+	for i, upper := range m.cfg.Buckets {
+		if seconds <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// 以 Prometheus 文本格式输出指标的 handler
+func (m *Metrics) Handler() gee.HandlerFunc {
+	return func(c *gee.Context) {
+		c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		m.WriteTo(c.Writer)
+	}
+}
+
+// 按 Prometheus 文本格式写出所有指标，序列按标签排序，输出是稳定的
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	ns := m.cfg.Namespace
+
+	m.mu.Lock()
+	requests := sortedKeys(m.requests)
+	fmt.Fprintf(&b, "# HELP %s_http_requests_total Total number of HTTP requests.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_http_requests_total counter\n", ns)
+	for _, key := range requests {
+		fmt.Fprintf(&b, "%s_http_requests_total%s %d\n", ns, key.labels(""), m.requests[key])
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_http_request_duration_seconds HTTP request latency in seconds.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_http_request_duration_seconds histogram\n", ns)
+	for _, key := range sortedKeys(m.durations) {
+		h := m.durations[key]
+		var cumulative uint64
+		for i, upper := range m.cfg.Buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_http_request_duration_seconds_bucket%s %d\n", ns, key.labels(formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(&b, "%s_http_request_duration_seconds_bucket%s %d\n", ns, key.labels("+Inf"), h.count)
+		fmt.Fprintf(&b, "%s_http_request_duration_seconds_sum%s %s\n", ns, key.labels(""), formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_http_request_duration_seconds_count%s %d\n", ns, key.labels(""), h.count)
+	}
+
+	fmt.Fprintf(&b, "# HELP %s_http_requests_in_flight Number of HTTP requests being served.\n", ns)
+	fmt.Fprintf(&b, "# TYPE %s_http_requests_in_flight gauge\n", ns)
+	for _, key := range sortedKeys(m.inFlight) {
+		fmt.Fprintf(&b, "%s_http_requests_in_flight%s %d\n", ns, key.labels(""), m.inFlight[key])
+	}
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func sortedKeys[V any](series map[seriesKey]V) []seriesKey {
+	keys := make([]seriesKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	return keys
+}
+
+// 输出 {method="GET",route="/users/:id",status="200"}，le 不为空时追加直方图的 le 标签
+func (key seriesKey) labels(le string) string {
+	var b strings.Builder
+	b.WriteString(`{method="` + escapeLabel(key.method) + `",route="` + escapeLabel(key.route) + `"`)
+	if key.status != "" {
+		b.WriteString(`,status="` + key.status + `"`)
+	}
+	if le != "" {
+		b.WriteString(`,le="` + le + `"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
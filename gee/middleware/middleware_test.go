@@ -0,0 +1,621 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gee"
+	"gee/geetest"
+	"gee/sessions"
+)
+
+func serve(r *gee.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORS(t *testing.T) {
+	r := gee.New()
+	r.Use(CORSWithConfig(CORSConfig{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+		MaxAge:           time.Hour,
+	}))
+	r.GET("/items", func(c *gee.Context) {
+		c.String(http.StatusOK, "items")
+	})
+
+	// 预检请求：路由只注册了 GET，OPTIONS 也会经过中间件
+	req := httptest.NewRequest(http.MethodOptions, "/items", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "Authorization")
+	w := serve(r, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: expected 204, got %d", w.Code)
+	}
+	for k, v := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Headers":     "Authorization",
+		"Access-Control-Max-Age":           "3600",
+	} {
+		if got := w.Header().Get(k); got != v {
+			t.Fatalf("preflight %s: expected %q, got %q", k, v, got)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w = serve(r, req)
+	if w.Body.String() != "items" || w.Header().Get("Access-Control-Expose-Headers") != "X-Total" {
+		t.Fatalf("unexpected simple response: %q %v", w.Body.String(), w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodOptions, "/items", nil)
+	req.Header.Set("Origin", "https://evil.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	if w = serve(r, req); w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin: expected 403 without CORS headers, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestGzip(t *testing.T) {
+	body := strings.Repeat("gee ", 100)
+	r := gee.New()
+	r.Use(Gzip())
+	r.GET("/text", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s", body)
+	})
+	r.GET("/png", func(c *gee.Context) {
+		c.Data(http.StatusOK, []byte("\x89PNG\r\n\x1a\n"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/text", nil)
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+	w := serve(r, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected gzip response, got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != body {
+		t.Fatalf("unexpected decompressed body %q", b)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/png", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if w = serve(r, req); w.Header().Get("Content-Encoding") != "" {
+		t.Fatal("images should not be compressed")
+	}
+	if w = serve(r, httptest.NewRequest(http.MethodGet, "/text", nil)); w.Body.String() != body {
+		t.Fatal("expected identity response without Accept-Encoding")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	r := gee.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s", GetRequestID(c))
+	})
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+	id := w.Header().Get(HeaderRequestID)
+	if len(id) != 32 || w.Body.String() != id {
+		t.Fatalf("expected a generated id, got header %q body %q", id, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "gateway-1")
+	if w = serve(r, req); w.Header().Get(HeaderRequestID) != "gateway-1" {
+		t.Fatalf("expected the incoming id to be propagated, got %q", w.Header().Get(HeaderRequestID))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := gee.New()
+	r.Use(RateLimitWithConfig(RateLimitConfig{
+		Rate:    1,
+		Burst:   2,
+		KeyFunc: func(c *gee.Context) string { return c.Req.Header.Get("X-API-Key") },
+	}))
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	request := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		return serve(r, req)
+	}
+	for i := 0; i < 2; i++ {
+		if w := request("a"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Content-Type") != gee.MIMEProblemJSON {
+		t.Fatalf("expected a problem response, got %q", w.Header().Get("Content-Type"))
+	}
+	if w = request("b"); w.Code != http.StatusOK {
+		t.Fatalf("keys should have separate buckets, got %d", w.Code)
+	}
+
+	// 自定义的 ErrorHandler 同样会处理限流产生的错误
+	r.ErrorHandler = func(c *gee.Context, err error) {
+		c.String(http.StatusTooManyRequests, "custom: %v", err)
+	}
+	if w = request("a"); w.Code != http.StatusTooManyRequests || w.Body.String() != "custom: 429 Too Many Requests: too many requests" {
+		t.Fatalf("expected the custom error handler, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newLimiter(2, 1)
+	l.now = func() time.Time { return now }
+	if ok, _, _ := l.take("k"); !ok {
+		t.Fatal("expected the first token")
+	}
+	if ok, _, wait := l.take("k"); ok || wait != 500*time.Millisecond {
+		t.Fatalf("expected to wait 500ms, got ok=%v wait=%v", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := l.take("k"); !ok {
+		t.Fatal("expected the bucket to refill")
+	}
+	now = now.Add(2 * time.Minute)
+	l.take("other")
+	if _, ok := l.buckets["k"]; ok {
+		t.Fatal("expected idle buckets to be swept")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := gee.New()
+	r.Use(Timeout(20 * time.Millisecond))
+	r.GET("/slow", func(c *gee.Context) {
+		select {
+		case <-c.Req.Context().Done():
+		case <-time.After(time.Second):
+			c.String(http.StatusOK, "done")
+		}
+	})
+	start := time.Now()
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != gee.MIMEProblemJSON {
+		t.Fatalf("expected a 503 problem, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("the request context was not cancelled")
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+	r := gee.New()
+	r.Use(BodyLimit(16))
+	r.POST("/", func(c *gee.Context) {
+		var p payload
+		if c.BindJSON(&p) != nil {
+			return
+		}
+		c.String(http.StatusOK, "%s", p.Name)
+	})
+
+	body := `{"name":"a long name"}`
+	if w := serve(r, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))); w.Code != http.StatusRequestEntityTooLarge ||
+		w.Header().Get("Content-Type") != gee.MIMEProblemJSON {
+		t.Fatalf("Content-Length: expected a 413 problem, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.ContentLength = -1 // chunked
+	req.Header.Set("Content-Type", "application/json")
+	if w := serve(r, req); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("chunked: expected 413, got %d", w.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"gee"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if w := serve(r, req); w.Body.String() != "gee" {
+		t.Fatalf("expected small bodies to pass, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	for _, useSession := range []bool{false, true} {
+		r := gee.New()
+		if useSession {
+			r.Use(sessions.Sessions(sessions.NewMemoryStore()))
+		}
+		r.Use(CSRFWithConfig(CSRFConfig{Session: useSession}))
+		r.SetFuncMap(CSRFTemplateFuncs())
+		r.LoadHTMLFS(fstest.MapFS{"form.tmpl": {Data: []byte(`{{define "form"}}<form>{{csrfField .ctx}}</form>{{end}}`)}}, "*.tmpl")
+		r.GET("/form", func(c *gee.Context) {
+			c.HTML(http.StatusOK, "form", gee.H{"ctx": c})
+		})
+		r.GET("/token", func(c *gee.Context) {
+			c.String(http.StatusOK, "%s", CSRFToken(c))
+		})
+		r.POST("/comments", func(c *gee.Context) {
+			c.String(http.StatusCreated, "created")
+		})
+
+		client := geetest.New(r)
+		client.POST("/comments").Expect(t).Status(http.StatusForbidden)
+		body := client.GET("/form").Expect(t).Status(http.StatusOK).BodyContains(`name="csrf_token"`).Response().Body.String()
+		token := strings.TrimSuffix(body[strings.Index(body, `value="`)+7:], `"></form>`)
+
+		client.POST("/comments").WithForm(url.Values{"csrf_token": {token}}).Expect(t).Status(http.StatusCreated)
+		// 令牌每次输出都不同，但都有效
+		other := client.GET("/token").Do().Body.String()
+		if other == token {
+			t.Fatal("CSRF tokens should be masked differently each time")
+		}
+		client.POST("/comments").WithHeader("X-CSRF-Token", other).Expect(t).Status(http.StatusCreated)
+		// 改动第一个字符，末尾的字符可能只包含 base64 的填充位，改动后解码结果不变
+		tampered := []byte(other)
+		if tampered[0] == 'A' {
+			tampered[0] = 'B'
+		} else {
+			tampered[0] = 'A'
+		}
+		client.POST("/comments").WithHeader("X-CSRF-Token", string(tampered)).Expect(t).Status(http.StatusForbidden)
+
+		// 其他客户端的令牌无效
+		geetest.New(r).POST("/comments").WithHeader("X-CSRF-Token", other).Expect(t).Status(http.StatusForbidden)
+	}
+}
+
+func TestCSRFUpload(t *testing.T) {
+	r := gee.New()
+	r.Upload = gee.UploadConfig{MaxFileSize: 10}
+	r.Use(CSRF())
+	r.GET("/token", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s", CSRFToken(c))
+	})
+	r.POST("/upload", gee.WrapE(func(c *gee.Context) error {
+		if _, err := c.FormFile("file"); err != nil {
+			return err
+		}
+		c.Status(http.StatusCreated)
+		return nil
+	}))
+	r.POST("/stream", gee.WrapE(func(c *gee.Context) error {
+		mr, err := c.MultipartReader()
+		if err != nil {
+			return err
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		c.String(http.StatusCreated, "%s", b)
+		return nil
+	}))
+
+	client := geetest.New(r)
+	token := client.GET("/token").Do().Body.String()
+	upload := func(path string, fields map[string]string, data []byte) *geetest.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range fields {
+			mw.WriteField(k, v)
+		}
+		fw, _ := mw.CreateFormFile("file", "a.bin")
+		fw.Write(data)
+		mw.Close()
+		return client.POST(path).WithBody(mw.FormDataContentType(), body.Bytes())
+	}
+
+	// 表单字段中的令牌同样经过 engine.Upload 的限制解析
+	upload("/upload", map[string]string{"csrf_token": token}, []byte("tiny")).Expect(t).Status(http.StatusCreated)
+	if w := upload("/upload", map[string]string{"csrf_token": token}, make([]byte, 1000)).Do(); w.Code == http.StatusCreated {
+		t.Fatalf("oversized file should be rejected, got %d", w.Code)
+	}
+	// 流式上传通过请求头提交令牌，请求体留给 handler 读取
+	upload("/stream", nil, []byte("tiny")).WithHeader("X-CSRF-Token", token).Expect(t).Status(http.StatusCreated).BodyContains("tiny")
+}
+
+func TestSecure(t *testing.T) {
+	r := gee.New()
+	r.SetTrustedProxies([]string{"192.0.2.1"})
+	r.Use(Secure())
+	r.GET("/", func(c *gee.Context) {})
+	admin := r.Group("/admin")
+	admin.Use(SecureWithConfig(SecureConfig{
+		ContentSecurityPolicy: "default-src 'self'",
+		FrameOptions:          "-",
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+	}))
+	admin.GET("/", func(c *gee.Context) {})
+
+	w := serve(r, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get("X-Frame-Options") != "DENY" || w.Header().Get("X-Content-Type-Options") != "nosniff" ||
+		w.Header().Get("Referrer-Policy") != "strict-origin-when-cross-origin" ||
+		w.Header().Get("Strict-Transport-Security") != "" || w.Header().Get("Content-Security-Policy") != "" {
+		t.Fatalf("unexpected default headers %v", w.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = serve(r, req)
+	if w.Header().Get("X-Frame-Options") != "" || w.Header().Get("Content-Security-Policy") != "default-src 'self'" ||
+		w.Header().Get("Strict-Transport-Security") != "max-age=3600; includeSubDomains" {
+		t.Fatalf("unexpected group headers %v", w.Header())
+	}
+}
+
+func TestBasicAuthAndAPIKey(t *testing.T) {
+	r := gee.New()
+	whoami := func(c *gee.Context) {
+		p, _ := GetPrincipal(c)
+		c.String(http.StatusOK, "%s/%s", p.Method, p.Subject)
+	}
+	r.GET("/admin", BasicAuth(Accounts{"tom": "secret"}), whoami)
+	r.GET("/api", APIKeyWithConfig(APIKeyConfig{Keys: map[string]string{"k1": "billing"}, Query: "api_key"}), whoami)
+
+	client := geetest.New(r)
+	client.GET("/admin").Expect(t).Status(http.StatusUnauthorized).Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+	for _, cred := range [][2]string{{"tom", "wrong"}, {"jerry", ""}, {"", ""}} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.SetBasicAuth(cred[0], cred[1])
+		if w := serve(r, req); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%v: expected 401, got %d", cred, w.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.SetBasicAuth("tom", "secret")
+	if w := serve(r, req); w.Code != http.StatusOK || w.Body.String() != "basic/tom" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+
+	client.GET("/api").Expect(t).Status(http.StatusUnauthorized)
+	client.GET("/api").WithHeader("X-API-Key", "k2").Expect(t).Status(http.StatusUnauthorized)
+	client.GET("/api").WithHeader("X-API-Key", "k1").Expect(t).Status(http.StatusOK).Body("apikey/billing")
+	client.GET("/api").WithQuery("api_key", "k1").Expect(t).Status(http.StatusOK).Body("apikey/billing")
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	r := gee.New()
+	r.Use(JWTWithConfig(JWTConfig{Key: secret, Issuer: "gee", Audience: "api", now: func() time.Time { return now }}))
+	r.GET("/me", func(c *gee.Context) {
+		p, _ := GetPrincipal(c)
+		c.String(http.StatusOK, "%s/%s", p.Subject, p.Claims.String("role"))
+	})
+
+	sign := func(alg string, key interface{}, claims Claims) string {
+		token, err := SignJWT(alg, key, "", claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := Claims{"sub": "tom", "role": "admin", "iss": "gee", "aud": []string{"web", "api"}, "exp": now.Add(time.Hour).Unix()}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	noneToken := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"tom"}`)) + "."
+
+	cases := []struct {
+		token  string
+		status int
+		err    error
+	}{
+		{sign("HS256", secret, valid), http.StatusOK, nil},
+		{sign("HS512", secret, valid), http.StatusOK, nil},
+		{"", http.StatusUnauthorized, nil},
+		{"a.b", http.StatusUnauthorized, ErrTokenMalformed},
+		{noneToken, http.StatusUnauthorized, ErrTokenSignature},
+		{sign("HS256", []byte("other"), valid), http.StatusUnauthorized, ErrTokenSignature},
+		{sign("RS256", rsaKey, valid), http.StatusUnauthorized, ErrTokenSignature},
+		{sign("HS256", secret, Claims{"sub": "tom", "iss": "gee", "aud": "api", "exp": now.Unix()}), http.StatusUnauthorized, ErrTokenExpired},
+		{sign("HS256", secret, Claims{"sub": "tom", "iss": "gee", "aud": "api"}), http.StatusUnauthorized, ErrTokenExpired},
+		{sign("HS256", secret, Claims{"iss": "gee", "aud": "api", "exp": now.Add(2 * time.Hour).Unix(), "nbf": now.Add(time.Hour).Unix()}), http.StatusUnauthorized, ErrTokenNotActive},
+		{sign("HS256", secret, Claims{"iss": "evil", "aud": "api", "exp": now.Add(time.Hour).Unix()}), http.StatusUnauthorized, ErrTokenIssuer},
+		{sign("HS256", secret, Claims{"iss": "gee", "aud": "web", "exp": now.Add(time.Hour).Unix()}), http.StatusUnauthorized, ErrTokenAudience},
+	}
+	for i, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if w := serve(r, req); w.Code != c.status {
+			t.Fatalf("%d: expected %d, got %d", i, c.status, w.Code)
+		}
+		if c.err != nil {
+			cfg := JWTConfig{Key: secret, Issuer: "gee", Audience: "api", now: func() time.Time { return now }}
+			if _, err := cfg.parse(c.token); !errors.Is(err, c.err) {
+				t.Fatalf("%d: expected %v, got %v", i, c.err, err)
+			}
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	key1, _ := rsa.GenerateKey(rand.Reader, 2048)
+	key2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS := func(modTime time.Time, keys map[string]*rsa.PrivateKey) {
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		for kid, key := range keys {
+			set.Keys = append(set.Keys, jwk{
+				Kty: "RSA", Kid: kid, Use: "sig",
+				N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		b, _ := json.Marshal(set)
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	writeJWKS(time.Unix(1, 0), map[string]*rsa.PrivateKey{"k1": key1})
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+
+	r := gee.New()
+	r.Use(JWTWithConfig(JWTConfig{KeySet: keys, Algorithms: []string{"RS256"}}))
+	r.GET("/me", func(c *gee.Context) {
+		p, _ := GetPrincipal(c)
+		c.String(http.StatusOK, "%s", p.Subject)
+	})
+	request := func(kid string, key *rsa.PrivateKey) int {
+		token, _ := SignJWT("RS256", key, kid, Claims{"sub": "tom", "exp": time.Now().Add(time.Hour).Unix()})
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return serve(r, req).Code
+	}
+
+	if request("k1", key1) != http.StatusOK || request("", key1) != http.StatusOK {
+		t.Fatal("token signed by k1 should be accepted")
+	}
+	if request("k2", key2) != http.StatusUnauthorized || request("k1", key2) != http.StatusUnauthorized {
+		t.Fatal("unknown key should be rejected")
+	}
+	// 轮换：加入 k2，未知的 kid 触发重新加载
+	writeJWKS(time.Unix(2, 0), map[string]*rsa.PrivateKey{"k1": key1, "k2": key2})
+	now = now.Add(2 * time.Second)
+	if request("k2", key2) != http.StatusOK || request("k1", key1) != http.StatusOK {
+		t.Fatal("rotated key should be accepted")
+	}
+	// 删除 k1 之后，等到检查间隔过去才会生效
+	writeJWKS(time.Unix(3, 0), map[string]*rsa.PrivateKey{"k2": key2})
+	if request("k1", key1) != http.StatusOK {
+		t.Fatal("k1 should still be cached before the refresh interval")
+	}
+	now = now.Add(time.Minute)
+	if request("k1", key1) != http.StatusUnauthorized {
+		t.Fatal("removed key should be rejected after refresh")
+	}
+
+	// 并发校验时只有到了检查时间才会去拿写锁
+	keys.SetRefreshInterval(time.Hour)
+	writeJWKS(time.Unix(4, 0), map[string]*rsa.PrivateKey{"k1": key1})
+	now = now.Add(2 * time.Minute)
+	var wg sync.WaitGroup
+	codes := make(chan int, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- request("k2", key2)
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("k2 should stay cached within the refresh interval, got %d", code)
+		}
+	}
+
+	// 检查间隔小于 1 秒时，强制刷新不会比定期检查等得更久
+	keys.SetRefreshInterval(100 * time.Millisecond)
+	keys.refresh(false)
+	writeJWKS(time.Unix(5, 0), map[string]*rsa.PrivateKey{"k2": key2})
+	now = now.Add(200 * time.Millisecond)
+	if !keys.refresh(true) {
+		t.Fatal("forced refresh should honor a sub-second interval")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.Recovery())
+	m := ServeMetrics(r, MetricsConfig{Buckets: []float64{0.1, 1}})
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+	var during string
+	r.GET("/users/:id", func(c *gee.Context) {
+		now = now.Add(500 * time.Millisecond)
+		var b strings.Builder
+		m.WriteTo(&b)
+		during = b.String()
+		c.String(http.StatusOK, "%s", c.Param("id"))
+	})
+	r.GET("/panic", func(c *gee.Context) {
+		panic("boom")
+	})
+
+	client := geetest.New(r)
+	client.GET("/users/1").Expect(t).Status(http.StatusOK)
+	client.GET("/users/2").Expect(t).Status(http.StatusOK)
+	client.GET("/missing").Expect(t).Status(http.StatusNotFound)
+	client.GET("/panic").Expect(t).Status(http.StatusInternalServerError)
+	// 非标准的方法合并为同一个序列
+	for _, method := range []string{"AAA", "BBB", "CCC"} {
+		client.Request(method, "/nope").Do()
+	}
+
+	if !strings.Contains(during, `gee_http_requests_in_flight{method="GET",route="/users/:id"} 1`) {
+		t.Fatalf("in-flight gauge should count the running request:\n%s", during)
+	}
+	body := client.GET("/metrics").Expect(t).
+		Status(http.StatusOK).
+		ContentType("text/plain; version=0.0.4; charset=utf-8").
+		Response().Body.String()
+	for _, line := range []string{
+		"# TYPE gee_http_requests_total counter",
+		`gee_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`gee_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`gee_http_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`gee_http_requests_total{method="other",route="unmatched",status="404"} 3`,
+		`gee_http_requests_in_flight{method="GET",route="/panic"} 0`,
+		`gee_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.1"} 0`,
+		`gee_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="1"} 2`,
+		`gee_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="+Inf"} 2`,
+		`gee_http_request_duration_seconds_sum{method="GET",route="/users/:id",status="200"} 1`,
+		`gee_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`gee_http_requests_in_flight{method="GET",route="/users/:id"} 0`,
+		`gee_http_requests_in_flight{method="GET",route="/metrics"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %q in:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/users/1") || strings.Contains(body, "AAA") {
+		t.Fatal("raw paths and methods should not be used as labels")
+	}
+}
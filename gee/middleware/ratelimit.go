@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gee"
+)
+
+/**
+ * 令牌桶限流的配置
+ * 每个 key 一个桶，桶的容量为 Burst，每秒补充 Rate 个令牌，每个请求消耗一个令牌
+ * KeyFunc 默认为 c.ClientIP()，可以改为按用户、API Key 等限流
+ */
+type RateLimitConfig struct {
+	Rate    float64
+	Burst   int
+	KeyFunc func(c *gee.Context) string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// 尝试从 key 的桶中取出一个令牌，失败时返回需要等待的时间
+func (l *limiter) take(key string) (ok bool, remaining int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, 0, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// 已经补满的桶与新建的桶没有区别，定期删除它们，避免 map 无限增长
+func (l *limiter) sweep(now time.Time) {
+	fill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= fill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// 按客户端 IP 限流，每秒 rate 个请求，允许 burst 个突发请求
+func RateLimit(rate float64, burst int) gee.HandlerFunc {
+	return RateLimitWithConfig(RateLimitConfig{Rate: rate, Burst: burst})
+}
+
+// 超出限制时交给 ErrorHandler 返回 429 Too Many Requests，并通过 Retry-After 告知客户端多久之后重试
+func RateLimitWithConfig(cfg RateLimitConfig) gee.HandlerFunc {
+	if cfg.Rate <= 0 || cfg.Burst <= 0 {
+		panic("gee: RateLimit requires a positive rate and burst")
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(c *gee.Context) string { return c.ClientIP() }
+	}
+	l := newLimiter(cfg.Rate, cfg.Burst)
+	limit := strconv.Itoa(cfg.Burst)
+	return func(c *gee.Context) {
+		ok, remaining, wait := l.take(cfg.KeyFunc(c))
+		c.SetHeader("X-RateLimit-Limit", limit)
+		c.SetHeader("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !ok {
+			c.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.Error(gee.NewHTTPError(http.StatusTooManyRequests, "too many requests"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
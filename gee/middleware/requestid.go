@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"gee"
+)
+
+const (
+	HeaderRequestID = "X-Request-ID"
+	// Context 中保存请求 ID 的 key
+	RequestIDKey = "RequestID"
+)
+
+/**
+ * 请求 ID 中间件的配置
+ * Header 默认为 X-Request-ID；Generator 默认生成 32 位十六进制的随机串
+ */
+type RequestIDConfig struct {
+	Header    string
+	Generator func() string
+}
+
+func RequestID() gee.HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+/**
+ * 请求头中已经带有合法的请求 ID 时（例如由网关生成）沿用它，否则生成新的
+ * 请求 ID 会写入响应头、请求头（方便向下游服务传递）以及 c.Keys[RequestIDKey]
+ */
+func RequestIDWithConfig(cfg RequestIDConfig) gee.HandlerFunc {
+	if cfg.Header == "" {
+		cfg.Header = HeaderRequestID
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = newRequestID
+	}
+	return func(c *gee.Context) {
+		id := c.Req.Header.Get(cfg.Header)
+		if !validRequestID(id) {
+			id = cfg.Generator()
+			c.Req.Header.Set(cfg.Header, id)
+		}
+		c.SetHeader(cfg.Header, id)
+		c.Set(RequestIDKey, id)
+		c.Next()
+	}
+}
+
+// 返回当前请求的 ID，没有使用 RequestID 中间件时返回 ""
+func GetRequestID(c *gee.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// 只接受长度有限的可打印 ASCII，防止客户端通过请求 ID 向日志注入内容
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
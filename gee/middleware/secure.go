@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"gee"
+)
+
+/**
+ * 安全相关响应头的配置，字符串字段为空时使用默认值，为 "-" 时不发送该响应头
+ * 在分组上再次使用 SecureWithConfig 时，它设置的值覆盖外层中间件设置的值，
+ * 配置为 "-" 的响应头会被删除，因此每个分组可以有自己的策略，例如
+ * r.Use(middleware.Secure())
+ * admin := r.Group("/admin")
+ * admin.Use(middleware.SecureWithConfig(middleware.SecureConfig{ContentSecurityPolicy: "default-src 'self'"}))
+ */
+type SecureConfig struct {
+	// Content-Security-Policy，默认不发送
+	ContentSecurityPolicy string
+	// 以 Content-Security-Policy-Report-Only 发送，只报告违规而不拦截
+	CSPReportOnly bool
+	// Strict-Transport-Security 的 max-age，默认 180 天，小于 0 时不发送；只在 HTTPS 请求中发送
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// X-Frame-Options，默认为 DENY
+	FrameOptions string
+	// Referrer-Policy，默认为 strict-origin-when-cross-origin
+	ReferrerPolicy string
+	// X-Content-Type-Options，默认为 nosniff
+	ContentTypeOptions string
+}
+
+func Secure() gee.HandlerFunc {
+	return SecureWithConfig(SecureConfig{})
+}
+
+func SecureWithConfig(cfg SecureConfig) gee.HandlerFunc {
+	if cfg.HSTSMaxAge == 0 {
+		cfg.HSTSMaxAge = 180 * 24 * time.Hour
+	}
+	if cfg.FrameOptions == "" {
+		cfg.FrameOptions = "DENY"
+	}
+	if cfg.ReferrerPolicy == "" {
+		cfg.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	if cfg.ContentTypeOptions == "" {
+		cfg.ContentTypeOptions = "nosniff"
+	}
+	cspHeader, otherCSPHeader := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+	if cfg.CSPReportOnly {
+		cspHeader, otherCSPHeader = otherCSPHeader, cspHeader
+	}
+	hsts := "-"
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge/time.Second), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	csp := cfg.ContentSecurityPolicy
+	if csp == "" {
+		csp = "-"
+	}
+
+	return func(c *gee.Context) {
+		header := c.Writer.Header()
+		set := func(key, value string) {
+			if value == "-" {
+				header.Del(key)
+			} else {
+				header.Set(key, value)
+			}
+		}
+		set("X-Frame-Options", cfg.FrameOptions)
+		set("Referrer-Policy", cfg.ReferrerPolicy)
+		set("X-Content-Type-Options", cfg.ContentTypeOptions)
+		set(cspHeader, csp)
+		header.Del(otherCSPHeader)
+		if c.IsHTTPS() {
+			set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
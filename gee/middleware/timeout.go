@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"gee"
+)
+
+/**
+ * 为每个请求设置超时，超时后 c.Req.Context() 会被取消
+ * handler 需要把 c.Req.Context() 传给数据库、下游调用等，或者自己检查 ctx.Done()，才能及时返回
+ * handler 返回时已经超时且还没有发出响应，则交给 ErrorHandler 返回 503 Service Unavailable
+ * handler 在同一个 goroutine 中执行，不会在超时后与 Context 的复用产生竞争
+ */
+func Timeout(d time.Duration) gee.HandlerFunc {
+	return func(c *gee.Context) {
+		ctx, cancel := context.WithTimeout(c.Req.Context(), d)
+		defer cancel()
+		c.Req = c.Req.WithContext(ctx)
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			c.Error(&gee.HTTPError{Status: http.StatusServiceUnavailable, Detail: "request timeout", Err: ctx.Err()})
+			c.Abort()
+		}
+	}
+}
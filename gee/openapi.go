@@ -0,0 +1,570 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * 路由的文档信息，用于生成 OpenAPI 文档，例如
+ * r.POST("/articles", create).Doc(gee.RouteDoc{
+ *     Summary:   "create an article",
+ *     Tags:      []string{"article"},
+ *     Request:   CreateArticle{},
+ *     Responses: map[int]interface{}{201: Article{}, 400: gee.ValidationErrors{}},
+ * })
+ *
+ * Request 的字段按照绑定时的规则生成：
+ *   - GET、HEAD、DELETE 的字段作为查询参数，参数名取自 form tag
+ *   - 其他请求方式作为 JSON 请求体，字段名取自 json tag；含有 *multipart.FileHeader 字段时作为 multipart/form-data
+ *   - 与路由参数（:id）同名的字段作为路径参数
+ *   - binding tag 中的 required、min、max、len、oneof、regex 会转换为对应的约束
+ * Responses 的值为 nil 时只输出描述，不输出响应体
+ */
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Request     interface{}
+	Responses   map[int]interface{}
+	Deprecated  bool
+}
+
+// 为路由添加文档信息，Any 注册的多个路由共享同一份文档
+func (r *Route) Doc(doc RouteDoc) *Route {
+	for _, info := range r.infos {
+		info.Doc = &doc
+	}
+	return r
+}
+
+// OpenAPI 3 文档，只包含 gee 生成的部分，可以在 OpenAPIConfig.Customize 中补充
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema `json:"schemas,omitempty"`
+	SecuritySchemes map[string]interface{}    `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationID string                      `json:"operationId,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"` // path 或 query
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+}
+
+/**
+ * OpenAPI 文档和 Swagger UI 的配置
+ * Path 为 JSON 文档的路由，默认为 /openapi.json；UIPath 为 Swagger UI 的路由，默认为 /docs，为 "-" 时不注册
+ * gee 只生成 Swagger UI 的 HTML 页面，没有内嵌 swagger-ui-dist 的脚本和样式，它们从 SwaggerUIAssets 加载，
+ * 默认为 unpkg 的 CDN；无法访问外网的环境必须改为自己托管的 swagger-ui-dist 目录（例如配合 StaticFS），否则页面是空白的
+ * Customize 在文档生成后调用，可以补充 securitySchemes 等 gee 无法推断的内容
+ */
+type OpenAPIConfig struct {
+	Info            OpenAPIInfo
+	Path            string
+	UIPath          string
+	SwaggerUIAssets string
+	Customize       func(doc *OpenAPIDocument)
+}
+
+/**
+ * 根据已注册的路由生成 OpenAPI 文档，HEAD、OPTIONS 路由只有添加了文档信息时才会输出
+ * engine.Host 注册的路由不会输出：不同主机上的相同路径在 paths 中无法区分
+ */
+func (engine *Engine) OpenAPI(info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	gen := &schemaGenerator{schemas: make(map[string]*OpenAPISchema), names: make(map[reflect.Type]string)}
+	for _, route := range engine.routes {
+		if route.Host != "" {
+			continue
+		}
+		if route.Doc == nil && (route.Method == http.MethodHead || route.Method == http.MethodOptions) {
+			continue
+		}
+		// OpenAPI 的路径参数都是必填的，可选参数展开为多个路径
+		for _, pattern := range expandOptional(route.Path) {
+			path := openAPIPath(pattern)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = gen.operation(route, pattern)
+		}
+	}
+	if len(gen.schemas) > 0 {
+		doc.Components.Schemas = gen.schemas
+	}
+	return doc
+}
+
+/**
+ * 注册 OpenAPI 文档和 Swagger UI 的路由
+ * 文档在第一次请求时生成，因此在此之后、启动服务之前注册的路由也会包含在内
+ */
+func (engine *Engine) ServeOpenAPI(cfg OpenAPIConfig) {
+	if cfg.Path == "" {
+		cfg.Path = "/openapi.json"
+	}
+	if cfg.UIPath == "" {
+		cfg.UIPath = "/docs"
+	}
+	if cfg.SwaggerUIAssets == "" {
+		cfg.SwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@5"
+	}
+	if cfg.Info.Title == "" {
+		cfg.Info.Title = "gee"
+	}
+	if cfg.Info.Version == "" {
+		cfg.Info.Version = "0.0.0"
+	}
+
+	var once sync.Once
+	var spec []byte
+	var specErr error
+	engine.GET(cfg.Path, func(c *Context) {
+		once.Do(func() {
+			doc := engine.OpenAPI(cfg.Info)
+			// 文档自身的路由不出现在文档中
+			delete(doc.Paths, cfg.Path)
+			delete(doc.Paths, cfg.UIPath)
+			if cfg.Customize != nil {
+				cfg.Customize(doc)
+			}
+			spec, specErr = json.MarshalIndent(doc, "", "  ")
+		})
+		c.writeJSON(http.StatusOK, MIMEJSON, spec, specErr)
+	})
+
+	if cfg.UIPath == "-" {
+		return
+	}
+	engine.GET(cfg.UIPath, func(c *Context) {
+		var buf bytes.Buffer
+		if err := swaggerUITemplate.Execute(&buf, H{"Title": cfg.Info.Title, "Assets": cfg.SwaggerUIAssets, "Spec": cfg.Path}); err != nil {
+			c.Error(err)
+			c.Fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.SetHeader("Content-Type", "text/html; charset=utf-8")
+		c.Data(http.StatusOK, buf.Bytes())
+	})
+}
+
+var swaggerUITemplate = template.Must(template.New("swagger-ui").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`))
+
+// /articles/:id<int> => /articles/{id}，/static/*filepath => /static/{filepath}
+func openAPIPath(pattern string) string {
+	parts := parsePattern(pattern)
+	for i, part := range parts {
+		if part[0] == ':' {
+			name, _, _ := parseParam(part)
+			parts[i] = "{" + name + "}"
+		} else if part[0] == '*' && len(part) > 1 {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// 路径参数的约束转换为 schema，例如 <int> => integer，正则约束 => pattern
+func constraintSchema(constraint string) *OpenAPISchema {
+	switch constraint {
+	case "":
+		return &OpenAPISchema{Type: "string"}
+	case "int":
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case "uint":
+		zero := 0.0
+		return &OpenAPISchema{Type: "integer", Format: "int64", Minimum: &zero}
+	case "float":
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case "alpha":
+		return &OpenAPISchema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	case "alnum":
+		return &OpenAPISchema{Type: "string", Pattern: "^[A-Za-z0-9]+$"}
+	case "uuid":
+		return &OpenAPISchema{Type: "string", Format: "uuid"}
+	}
+	return &OpenAPISchema{Type: "string", Pattern: "^(?:" + constraint + ")$"}
+}
+
+// 记录已经生成的具名结构体，它们放在 components/schemas 中通过 $ref 引用
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGenerator) operation(route *RouteInfo, pattern string) *OpenAPIOperation {
+	op := &OpenAPIOperation{OperationID: route.Name, Responses: make(map[string]*OpenAPIResponse)}
+	var doc RouteDoc
+	if route.Doc != nil {
+		doc = *route.Doc
+	}
+	op.Summary, op.Description, op.Tags, op.Deprecated = doc.Summary, doc.Description, doc.Tags, doc.Deprecated
+
+	// 路径参数，类型取自约束，默认为字符串，Request 中有同名字段时使用字段的类型
+	pathParams := make(map[string]*OpenAPIParameter)
+	for _, part := range parsePattern(pattern) {
+		if part[0] == ':' {
+			name, constraint, _ := parseParam(part)
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name: name, In: "path", Required: true, Schema: constraintSchema(constraint),
+			})
+		} else if part[0] == '*' && len(part) > 1 {
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name: part[1:], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"},
+			})
+		}
+	}
+	for i := range op.Parameters {
+		pathParams[op.Parameters[i].Name] = &op.Parameters[i]
+	}
+
+	if doc.Request != nil {
+		t := indirectType(reflect.TypeOf(doc.Request))
+		switch {
+		case t.Kind() != reflect.Struct:
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]OpenAPIMediaType{
+				MIMEJSON: {Schema: g.schema(t)},
+			}}
+		case route.Method == http.MethodGet || route.Method == http.MethodHead || route.Method == http.MethodDelete:
+			g.queryParams(op, t, pathParams)
+		case hasFileField(t):
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]OpenAPIMediaType{
+				MIMEMultipartPOSTForm: {Schema: g.formSchema(t, pathParams)},
+			}}
+		default:
+			g.typePathParams(t, pathParams)
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: map[string]OpenAPIMediaType{
+				MIMEJSON: {Schema: g.schema(t)},
+			}}
+		}
+	}
+
+	if len(doc.Responses) == 0 {
+		op.Responses["200"] = &OpenAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+	for code, body := range doc.Responses {
+		resp := &OpenAPIResponse{Description: http.StatusText(code)}
+		if body != nil {
+			resp.Content = map[string]OpenAPIMediaType{MIMEJSON: {Schema: g.schema(reflect.TypeOf(body))}}
+		}
+		op.Responses[strconv.Itoa(code)] = resp
+	}
+	return op
+}
+
+// JSON 请求体中与路径参数同名的字段决定路径参数的类型，字段本身仍然保留在请求体中
+func (g *schemaGenerator) typePathParams(t reflect.Type, pathParams map[string]*OpenAPIParameter) {
+	forEachField(t, "json", func(name string, field reflect.StructField) {
+		if p, ok := pathParams[name]; ok {
+			p.Schema = g.schema(field.Type)
+		}
+	})
+}
+
+func (g *schemaGenerator) queryParams(op *OpenAPIOperation, t reflect.Type, pathParams map[string]*OpenAPIParameter) {
+	forEachField(t, "form", func(name string, field reflect.StructField) {
+		schema := g.schema(field.Type)
+		required := applyRules(schema, field)
+		if p, ok := pathParams[name]; ok {
+			p.Schema = schema
+			return
+		}
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: name, In: "query", Required: required, Schema: schema})
+	})
+}
+
+// multipart/form-data 请求体，文件字段输出为 binary 字符串
+func (g *schemaGenerator) formSchema(t reflect.Type, pathParams map[string]*OpenAPIParameter) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	forEachField(t, "form", func(name string, field reflect.StructField) {
+		var schema *OpenAPISchema
+		switch field.Type {
+		case fileHeaderType:
+			schema = &OpenAPISchema{Type: "string", Format: "binary"}
+		case fileHeadersType:
+			schema = &OpenAPISchema{Type: "array", Items: &OpenAPISchema{Type: "string", Format: "binary"}}
+		default:
+			schema = g.schema(field.Type)
+		}
+		if applyRules(schema, field) {
+			s.Required = append(s.Required, name)
+		}
+		if p, ok := pathParams[name]; ok {
+			p.Schema = schema
+			return
+		}
+		s.Properties[name] = schema
+	})
+	return s
+}
+
+func hasFileField(t reflect.Type) bool {
+	found := false
+	forEachField(t, "form", func(name string, field reflect.StructField) {
+		if field.Type == fileHeaderType || field.Type == fileHeadersType {
+			found = true
+		}
+	})
+	return found
+}
+
+/**
+ * 遍历导出字段，字段名取自 tagKey 对应的 tag，没有时使用字段名，tag 为 "-" 的字段跳过
+ * 没有名字的内嵌结构体展开到外层，与 encoding/json 和 mapStruct 的行为一致
+ */
+func forEachField(t reflect.Type, tagKey string, fn func(name string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get(tagKey), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && indirectType(field.Type).Kind() == reflect.Struct {
+			forEachField(indirectType(field.Type), tagKey, fn)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fn(name, field)
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+var schemaNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (g *schemaGenerator) schema(t reflect.Type) *OpenAPISchema {
+	nullable := t.Kind() == reflect.Ptr
+	t = indirectType(t)
+
+	switch t {
+	case timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time", Nullable: nullable}
+	case durationType:
+		return &OpenAPISchema{Type: "integer", Format: "int64", Nullable: nullable}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &OpenAPISchema{Type: "string", Nullable: nullable}
+	}
+
+	var s *OpenAPISchema
+	switch t.Kind() {
+	case reflect.Bool:
+		s = &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		s = &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		s = &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		s = &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		s = &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		s = &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 { // encoding/json 把 []byte 编码为 base64
+			s = &OpenAPISchema{Type: "string", Format: "byte"}
+		} else {
+			s = &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+		}
+	case reflect.Map:
+		s = &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			s = g.structSchema(t)
+			break
+		}
+		// OpenAPI 3.0 中 $ref 不能与 nullable 并列
+		return &OpenAPISchema{Ref: "#/components/schemas/" + g.structName(t)}
+	default: // interface{} 等任意值
+		s = &OpenAPISchema{}
+	}
+	s.Nullable = nullable
+	return s
+}
+
+// 具名结构体只生成一次，先登记名字再生成字段，支持递归引用自身的类型
+func (g *schemaGenerator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := schemaNameRegexp.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = schemaNameRegexp.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:]+"."+t.Name(), "_")
+	}
+	g.names[t] = name
+	g.schemas[name] = &OpenAPISchema{Type: "object"}
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	forEachField(t, "json", func(name string, field reflect.StructField) {
+		schema := g.schema(field.Type)
+		if applyRules(schema, field) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = schema
+	})
+	return s
+}
+
+/**
+ * 把 binding tag 中的规则转换为 schema 的约束，返回字段是否必填
+ * $ref 不能与其他约束并列，引用类型只处理 required
+ */
+func applyRules(s *OpenAPISchema, field reflect.StructField) bool {
+	tag := field.Tag.Get("binding")
+	if tag == "" || tag == "-" {
+		return false
+	}
+	required := false
+	for _, r := range parseRules(tag) {
+		if r.name == "required" {
+			required = true
+			continue
+		}
+		if s.Ref != "" {
+			continue
+		}
+		switch r.name {
+		case "min", "max", "len":
+			bound, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				continue
+			}
+			n := int(bound)
+			switch s.Type {
+			case "integer", "number":
+				if r.name != "max" {
+					s.Minimum = &bound
+				}
+				if r.name != "min" {
+					s.Maximum = &bound
+				}
+			case "string":
+				if r.name != "max" {
+					s.MinLength = &n
+				}
+				if r.name != "min" {
+					s.MaxLength = &n
+				}
+			case "array":
+				if r.name != "max" {
+					s.MinItems = &n
+				}
+				if r.name != "min" {
+					s.MaxItems = &n
+				}
+			}
+		case "oneof":
+			for _, option := range strings.Fields(r.param) {
+				if s.Type == "integer" || s.Type == "number" {
+					if f, err := strconv.ParseFloat(option, 64); err == nil {
+						s.Enum = append(s.Enum, f)
+						continue
+					}
+				}
+				s.Enum = append(s.Enum, option)
+			}
+		case "regex":
+			s.Pattern = r.param
+		}
+	}
+	return required
+}
@@ -0,0 +1,102 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type docAuthor struct {
+	Name string `json:"name"`
+}
+
+type docArticle struct {
+	ID        int64        `json:"id"`
+	Title     string       `json:"title" binding:"required,min=3,max=64"`
+	Status    string       `json:"status" binding:"oneof=draft published"`
+	Author    *docAuthor   `json:"author,omitempty"`
+	Related   []docArticle `json:"related,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	internal  string
+}
+
+type docListQuery struct {
+	Page int    `form:"page" binding:"min=1"`
+	Tag  string `form:"tag"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := New()
+	r.GET("/articles", func(c *Context) {}).Doc(RouteDoc{
+		Summary:   "list articles",
+		Request:   docListQuery{},
+		Responses: map[int]interface{}{200: []docArticle{}},
+	})
+	r.POST("/articles/:id", func(c *Context) {}).Name("article.update").Doc(RouteDoc{
+		Tags:      []string{"article"},
+		Request:   &docArticle{},
+		Responses: map[int]interface{}{200: docArticle{}, 400: ValidationErrors{}, 404: nil},
+	})
+	r.HEAD("/articles", func(c *Context) {})
+	// 其他主机上的同名路由不会覆盖 /articles
+	r.Host("admin.example.com").GET("/articles", func(c *Context) {}).Doc(RouteDoc{Summary: "admin articles"})
+	r.ServeOpenAPI(OpenAPIConfig{Info: OpenAPIInfo{Title: "blog", Version: "1.0"}})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 2 || doc.Paths["/articles"]["head"] != nil || doc.Paths["/docs"] != nil {
+		t.Fatalf("unexpected paths %v", doc.Paths)
+	}
+
+	list := doc.Paths["/articles"]["get"]
+	if list.Summary != "list articles" {
+		t.Fatalf("host routes should not be documented, got %q", list.Summary)
+	}
+	if len(list.Parameters) != 2 || list.Parameters[0].Name != "page" || list.Parameters[0].In != "query" ||
+		*list.Parameters[0].Schema.Minimum != 1 {
+		t.Fatalf("unexpected query parameters %+v", list.Parameters)
+	}
+	if list.Responses["200"].Content[MIMEJSON].Schema.Items.Ref != "#/components/schemas/docArticle" {
+		t.Fatalf("unexpected list response %+v", list.Responses["200"])
+	}
+
+	update := doc.Paths["/articles/{id}"]["post"]
+	if update.OperationID != "article.update" || update.Parameters[0].In != "path" ||
+		update.Parameters[0].Schema.Type != "integer" {
+		t.Fatalf("unexpected update operation %+v", update)
+	}
+	if update.Responses["404"].Description != "Not Found" || update.Responses["404"].Content != nil {
+		t.Fatalf("unexpected 404 response %+v", update.Responses["404"])
+	}
+
+	article := doc.Components.Schemas["docArticle"]
+	if article == nil {
+		t.Fatal("docArticle schema is missing")
+	}
+	title := article.Properties["title"]
+	if len(article.Required) != 1 || article.Required[0] != "title" || *title.MinLength != 3 || *title.MaxLength != 64 {
+		t.Fatalf("unexpected title schema %+v, required %v", title, article.Required)
+	}
+	if len(article.Properties["status"].Enum) != 2 || article.Properties["created_at"].Format != "date-time" {
+		t.Fatal("unexpected status/created_at schema")
+	}
+	if article.Properties["related"].Items.Ref != "#/components/schemas/docArticle" {
+		t.Fatalf("recursive reference is wrong: %+v", article.Properties["related"])
+	}
+	if _, ok := article.Properties["internal"]; ok {
+		t.Fatal("unexported fields should be skipped")
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if !strings.Contains(w.Body.String(), `url: "/openapi.json"`) || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("unexpected swagger ui page %s", w.Body.String())
+	}
+}
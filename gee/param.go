@@ -0,0 +1,130 @@
+package gee
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * 解析参数片段，支持类型约束、正则约束和可选参数，例如
+ * :id         name=id
+ * :id<int>    name=id, constraint=int
+ * :name<[a-z]+\.txt>  name=name, constraint=[a-z]+\.txt
+ * :month<int>?        name=month, constraint=int, optional=true
+ */
+func parseParam(part string) (name, constraint string, optional bool) {
+	name = part[1:]
+	if strings.HasSuffix(name, "?") {
+		name, optional = name[:len(name)-1], true
+	}
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		name, constraint = name[:i], name[i+1:len(name)-1]
+	}
+	return
+}
+
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z' || '0' <= s[i] && s[i] <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// 内置的类型约束，其他约束都按正则表达式处理
+var paramTypes = map[string]func(s string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"float": func(s string) bool {
+		_, err := strconv.ParseFloat(s, 64)
+		return err == nil
+	},
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"uuid":  uuidRegexp.MatchString,
+}
+
+/**
+ * 返回约束对应的匹配函数，正则约束需要匹配整个片段
+ * 正则写在路由里，写错属于编程错误，注册时直接 panic
+ */
+func paramMatcher(constraint string, pattern string) func(s string) bool {
+	if match, ok := paramTypes[constraint]; ok {
+		return match
+	}
+	re, err := regexp.Compile("^(?:" + constraint + ")$")
+	if err != nil {
+		panic(fmt.Sprintf("gee: invalid constraint <%s> in %s: %v", constraint, pattern, err))
+	}
+	return re.MatchString
+}
+
+/**
+ * 把末尾的可选参数展开为多个 pattern，例如
+ * /archive/:year<int>/:month<int>? => /archive/:year<int>, /archive/:year<int>/:month<int>
+ * 可选参数只能出现在末尾，之后不能再有必选的片段
+ */
+func expandOptional(pattern string) []string {
+	parts := parsePattern(pattern)
+	first := -1
+	for i, part := range parts {
+		optional := false
+		if part[0] == ':' {
+			_, _, optional = parseParam(part)
+		}
+		if optional && first < 0 {
+			first = i
+		} else if !optional && first >= 0 {
+			panic(fmt.Sprintf("gee: optional parameter in %s must be at the end", pattern))
+		}
+	}
+	if first < 0 {
+		return []string{pattern}
+	}
+	patterns := make([]string, 0, len(parts)-first+1)
+	for i := first; i <= len(parts); i++ {
+		segments := make([]string, i)
+		for j, part := range parts[:i] {
+			segments[j] = strings.TrimSuffix(part, "?")
+		}
+		patterns = append(patterns, "/"+strings.Join(segments, "/"))
+	}
+	return patterns
+}
+
+// 把路由参数解析为 int，参数不存在或格式错误时返回错误；使用 :id<int> 约束时不会出错
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamUint64(key string) (uint64, error) {
+	return strconv.ParseUint(c.Param(key), 10, 64)
+}
+
+func (c *Context) ParamFloat64(key string) (float64, error) {
+	return strconv.ParseFloat(c.Param(key), 64)
+}
@@ -32,8 +32,26 @@ func Recovery() HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
-				c.Fail(http.StatusInternalServerError, "Internal Server Error")
+				stack := trace(message)
+				// 外层有 LoggerWithConfig 时，panic 日志与访问日志写入同一个输出端
+				if c.logSink != nil {
+					c.logSink.write("error", []logField{
+						{"method", c.Method},
+						{"path", c.Path},
+						{"route", c.fullPath},
+						{"request_id", requestID(c)},
+						{"panic", message},
+						{"trace", stack},
+					}, stack+"\n\n")
+				} else {
+					log.Printf("%s\n\n", stack)
+				}
+				c.Abort()
+				c.Error(&HTTPError{Status: http.StatusInternalServerError, Err: fmt.Errorf("panic: %s", message)})
+				// panic 跳过了 c.Next() 末尾的错误处理，在这里交给 ErrorHandler
+				// 即使 handler 在 panic 之前设置过状态码也要返回 500；响应头已经发出时无法再修改状态码，什么也不做
+				c.errorsHandled = false
+				c.runErrorHandler()
 			}
 		}()
 		// defer recover 机制只能针对于当前函数
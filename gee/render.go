@@ -0,0 +1,312 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MIMEYAML     = "application/x-yaml"
+	MIMEPROTOBUF = "application/x-protobuf"
+	MIMEJSONP    = "application/javascript"
+)
+
+// 把 data 编码后写入 w，Content-Type 由调用方根据注册时的 MIME 类型设置
+type Renderer interface {
+	Render(w io.Writer, data interface{}) error
+}
+
+// 让普通函数实现 Renderer 接口
+type RendererFunc func(w io.Writer, data interface{}) error
+
+func (f RendererFunc) Render(w io.Writer, data interface{}) error {
+	return f(w, data)
+}
+
+/**
+ * encoding/xml 不支持 map，为 H 实现 xml.Marshaler
+ * 根元素为 <map>，每个键对应一个子元素，按键的字典序输出
+ */
+func (h H) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: "map"}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := e.EncodeElement(h[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func renderJSON(w io.Writer, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+func renderXML(w io.Writer, data interface{}) error {
+	return xml.NewEncoder(w).Encode(data)
+}
+
+func renderYAML(w io.Writer, data interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// data 必须是 protoc-gen-go 生成的消息（proto.Message）
+func renderProtoBuf(w io.Writer, data interface{}) error {
+	m, ok := data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gee: %T is not a proto.Message, register a renderer for %s", data, MIMEPROTOBUF)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// 内置的渲染器，顺序也是 Negotiate 时的默认优先级
+func defaultRenderers() (map[string]Renderer, []string) {
+	offers := []string{MIMEJSON, MIMEXML, MIMEXML2, MIMEYAML, MIMEPROTOBUF}
+	renderers := map[string]Renderer{
+		MIMEJSON:     RendererFunc(renderJSON),
+		MIMEXML:      RendererFunc(renderXML),
+		MIMEXML2:     RendererFunc(renderXML),
+		MIMEYAML:     RendererFunc(renderYAML),
+		MIMEPROTOBUF: RendererFunc(renderProtoBuf),
+	}
+	return renderers, offers
+}
+
+// 注册或覆盖某种 MIME 类型的渲染器，c.Render 和 c.Negotiate 都会使用它
+func (engine *Engine) RegisterRenderer(mimeType string, r Renderer) {
+	if _, ok := engine.renderers[mimeType]; !ok {
+		engine.renderOffers = append(engine.renderOffers, mimeType)
+	}
+	engine.renderers[mimeType] = r
+}
+
+// 直接通过 newContext 创建的 Context 没有 engine，使用内置的渲染器
+func (c *Context) renderers() (map[string]Renderer, []string) {
+	if c.engine == nil {
+		return defaultRenderers()
+	}
+	return c.engine.renderers, c.engine.renderOffers
+}
+
+/**
+ * 使用注册的渲染器响应，body 先编码到缓冲区，
+ * 编码失败时还可以改为返回 500，而不是发出一半的响应
+ */
+func (c *Context) Render(code int, mimeType string, data interface{}) {
+	renderers, _ := c.renderers()
+	r, ok := renderers[mimeType]
+	if !ok {
+		c.Error(fmt.Errorf("gee: no renderer registered for %s", mimeType))
+		http.Error(c.Writer, "no renderer for "+mimeType, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, data); err != nil {
+		c.Error(err)
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.SetHeader("Content-Type", mimeType)
+	c.Status(code)
+	c.Writer.Write(buf.Bytes())
+}
+
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, MIMEXML, obj)
+}
+
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, MIMEYAML, obj)
+}
+
+func (c *Context) ProtoBuf(code int, obj interface{}) {
+	c.Render(code, MIMEPROTOBUF, obj)
+}
+
+// 带缩进的 JSON，方便调试时直接阅读
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	b, err := json.MarshalIndent(obj, "", "    ")
+	c.writeJSON(code, MIMEJSON, b, err)
+}
+
+// 不转义 <、>、& 等 HTML 字符的 JSON
+func (c *Context) PureJSON(code int, obj interface{}) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(obj)
+	c.writeJSON(code, MIMEJSON, buf.Bytes(), err)
+}
+
+/**
+ * 顶层是数组时加上 engine.SecureJSONPrefix（默认 while(1);），
+ * 防止旧浏览器通过 <script> 标签劫持 JSON 数组
+ */
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	b, err := json.Marshal(obj)
+	if err == nil && bytes.HasPrefix(b, []byte("[")) {
+		prefix := "while(1);"
+		if c.engine != nil {
+			prefix = c.engine.SecureJSONPrefix
+		}
+		b = append([]byte(prefix), b...)
+	}
+	c.writeJSON(code, MIMEJSON, b, err)
+}
+
+// 合法的 JavaScript 标识符，允许 a.b.c 形式的属性访问
+var jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+/**
+ * JSONP，回调函数名取自 url 参数 callback
+ * 回调名不是合法的标识符时退化为普通 JSON，避免向页面注入脚本
+ * 前面的 /**\/ 用于防御 Rosetta Flash 这类利用回调名伪造文件头的攻击
+ */
+func (c *Context) JSONP(code int, obj interface{}) {
+	callback := c.Query("callback")
+	b, err := json.Marshal(obj)
+	if callback == "" || !jsonpCallbackRegexp.MatchString(callback) {
+		c.writeJSON(code, MIMEJSON, b, err)
+		return
+	}
+	if err == nil {
+		b = []byte("/**/" + callback + "(" + string(b) + ");")
+	}
+	c.writeJSON(code, MIMEJSONP, b, err)
+}
+
+func (c *Context) writeJSON(code int, contentType string, b []byte, err error) {
+	if err != nil {
+		c.Error(err)
+		http.Error(c.Writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.SetHeader("Content-Type", contentType)
+	c.Status(code)
+	c.Writer.Write(b)
+}
+
+/**
+ * 内容协商：根据 Accept 头从 offers 中选出客户端最想要的 MIME 类型并渲染 data
+ * offers 为空时使用 Engine 上注册的全部渲染器，按注册顺序作为服务端的优先级
+ * 没有可接受的类型时返回 406 Not Acceptable
+ */
+func (c *Context) Negotiate(code int, data interface{}, offers ...string) {
+	if len(offers) == 0 {
+		_, offers = c.renderers()
+	}
+	format := c.NegotiateFormat(offers...)
+	if format == "" {
+		c.Error(errors.New("gee: the accepted formats are not offered by the server"))
+		c.Fail(http.StatusNotAcceptable, "the accepted formats are not offered by the server")
+		return
+	}
+	c.Render(code, format, data)
+}
+
+type acceptSpec struct {
+	mimeType string
+	q        float64
+}
+
+// 解析 Accept 头，例如 text/html, application/json;q=0.9, */*;q=0.1
+func parseAccept(header string) []acceptSpec {
+	specs := make([]acceptSpec, 0)
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		mimeType := strings.ToLower(strings.TrimSpace(parts[0]))
+		if mimeType == "" {
+			continue
+		}
+		spec := acceptSpec{mimeType: mimeType, q: 1}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					spec.q = q
+				}
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+/**
+ * 返回 offers 中与 Accept 头最匹配的类型，都不可接受时返回 ""
+ * 每个 offer 的权重取自最具体的匹配项（type/subtype > type/* > *\/*），
+ * 权重相同时按 offers 的顺序，没有 Accept 头时返回第一个 offer
+ */
+func (c *Context) NegotiateFormat(offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	header := c.Req.Header.Get("Accept")
+	if header == "" {
+		return offers[0]
+	}
+	specs := parseAccept(header)
+
+	type candidate struct {
+		offer string
+		q     float64
+	}
+	candidates := make([]candidate, 0, len(offers))
+	for _, offer := range offers {
+		best, specificity := -1.0, -1
+		lower := strings.ToLower(offer)
+		for _, spec := range specs {
+			s := -1
+			switch {
+			case spec.mimeType == lower:
+				s = 2
+			case strings.HasSuffix(spec.mimeType, "/*") && strings.HasPrefix(lower, strings.TrimSuffix(spec.mimeType, "*")):
+				s = 1
+			case spec.mimeType == "*/*":
+				s = 0
+			}
+			if s > specificity {
+				best, specificity = spec.q, s
+			}
+		}
+		if best > 0 {
+			candidates = append(candidates, candidate{offer, best})
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].offer
+}
@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRenderYAMLAndProtoBuf(t *testing.T) {
+	type item struct {
+		Name string   `yaml:"name"`
+		Tags []string `yaml:"tags,omitempty"`
+	}
+	r := New()
+	r.GET("/yaml", func(c *Context) {
+		c.YAML(http.StatusOK, H{"items": []item{{Name: "a", Tags: []string{"x", "y"}}, {Name: "true"}}, "count": 2})
+	})
+	r.GET("/proto", func(c *Context) {
+		c.ProtoBuf(http.StatusOK, wrapperspb.String("geektutu"))
+	})
+	r.GET("/not-proto", func(c *Context) {
+		c.ProtoBuf(http.StatusOK, H{"name": "geektutu"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/yaml", nil))
+	expect := `count: 2
+items:
+  - name: a
+    tags:
+      - x
+      - "y"
+  - name: "true"
+`
+	if w.Header().Get("Content-Type") != MIMEYAML || w.Body.String() != expect {
+		t.Fatalf("unexpected yaml %q:\n%s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proto", nil))
+	var msg wrapperspb.StringValue
+	if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil || msg.GetValue() != "geektutu" {
+		t.Fatalf("unexpected protobuf body %q: %v", w.Body.Bytes(), err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/not-proto", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("rendering a non-proto value should fail, got %d", w.Code)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := New()
+	r.RegisterRenderer("text/csv", RendererFunc(func(w io.Writer, data interface{}) error {
+		_, err := io.WriteString(w, "name\ngeektutu\n")
+		return err
+	}))
+	r.GET("/user", func(c *Context) {
+		c.Negotiate(http.StatusOK, H{"name": "geektutu"})
+	})
+
+	cases := []struct {
+		accept, contentType string
+		code                int
+	}{
+		{"", MIMEJSON, http.StatusOK},
+		{"application/xml;q=0.9, application/x-yaml", MIMEYAML, http.StatusOK},
+		{"text/*", "text/xml", http.StatusOK},
+		{"text/csv, */*;q=0.1", "text/csv", http.StatusOK},
+		{"image/png", MIMEJSON, http.StatusNotAcceptable},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Accept", c.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.code || w.Header().Get("Content-Type") != c.contentType {
+			t.Fatalf("Accept %q: expect %d %s, got %d %s", c.accept, c.code, c.contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestJSONVariants(t *testing.T) {
+	r := New()
+	r.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, H{"a": 1}) })
+	r.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []int{1, 2}) })
+	r.GET("/pure", func(c *Context) { c.PureJSON(http.StatusOK, H{"html": "<b>"}) })
+
+	cases := []struct {
+		path, body string
+	}{
+		{"/jsonp?callback=app.cb", "/**/app.cb({\"a\":1});"},
+		{"/jsonp?callback=alert(1)//", "{\"a\":1}"}, // 非法回调名退化为普通 JSON
+		{"/secure", "while(1);[1,2]"},
+		{"/pure", "{\"html\":\"<b>\"}\n"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Body.String() != c.body {
+			t.Fatalf("%s: expect %q, got %q", c.path, c.body, w.Body.String())
+		}
+	}
+}
@@ -1,21 +1,21 @@
 package gee
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 type router struct {
-	roots    map[string]*node       // 存储每种请求方式的 Trie 树根节点
-	handlers map[string]HandlerFunc // 存储每个 pattern 的方法
+	roots map[string]*node // 存储每种请求方式的 Trie 树根节点
+	// 每个 pattern 的中间件链和处理函数挂载在对应的叶子节点上
 }
 
 // roots key: ['GET'], ['POST']
-// handlers key: ['GET-/p/:lang/doc'], ['POST-/p/book']
 func newRouter() *router {
 	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		roots: make(map[string]*node),
 	}
 }
 
@@ -36,61 +36,166 @@ func parsePattern(pattern string) []string {
 	return parts
 }
 
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+/**
+ * 注册路由，pattern 会先被规范化，例如 /p//:lang/ -> /p/:lang
+ * 以下情况属于有歧义的注册，直接 panic，而不是静默覆盖：
+ * 1. 同一请求方式下重复注册同一个 pattern
+ * 2. 同一位置出现约束相同但名字不同的参数，或不同名字的通配，例如 /p/:lang 与 /p/:name/doc
+ *    约束不同的参数可以共存，例如 /user/:id<int> 与 /user/:name
+ * 3. 通配片段之后还有其他片段，例如 /p/*name/doc
+ * 4. 参数名或通配名缺失，例如 /p/:
+ */
+func (r *router) addRoute(method string, pattern string, handlers []HandlerFunc) *node {
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, part := range segments {
+		if strings.HasPrefix(part, ":") {
+			if name, _, optional := parseParam(part); name == "" {
+				panic(fmt.Sprintf("gee: wildcard in %s must be named", pattern))
+			} else if optional {
+				panic(fmt.Sprintf("gee: optional parameter in %s must be expanded before registration", pattern))
+			}
+		}
+		if strings.HasPrefix(part, "*") && i != len(segments)-1 {
+			panic(fmt.Sprintf("gee: catch-all in %s must be the last segment", pattern))
+		}
+	}
 	parts := parsePattern(pattern)
+	pattern = "/" + strings.Join(parts, "/")
 
-	key := method + "-" + pattern
 	_, ok := r.roots[method]
 	if !ok {
 		r.roots[method] = &node{}
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler
+	leaf := r.roots[method].insert(pattern, pattern)
+	if leaf.pattern != "" {
+		panic(fmt.Sprintf("gee: duplicate route %s %s", method, pattern))
+	}
+	leaf.pattern = pattern
+	leaf.handlers = handlers
+	leaf.paramNames = nil
+	for _, part := range parts {
+		if part[0] == ':' {
+			name, _, _ := parseParam(part)
+			leaf.paramNames = append(leaf.paramNames, name)
+		} else if part[0] == '*' && len(part) > 1 {
+			leaf.paramNames = append(leaf.paramNames, part[1:])
+		}
+	}
+	return leaf
+}
+
+/**
+ * 规范化请求路径：合并连续的 '/'，去掉末尾的 '/'
+ * 与 parsePattern 对 pattern 的处理保持一致
+ * 已经规范的路径原样返回，不产生内存分配
+ */
+func cleanPath(path string) string {
+	clean := path != "" && path[0] == '/' && (len(path) == 1 || path[len(path)-1] != '/') &&
+		!strings.Contains(path, "//")
+	if clean {
+		return path
+	}
+	return "/" + strings.Join(parsePattern(path), "/")
 }
 
 func (r *router) getRoute(method string, path string) (*node, map[string]string) {
-	searchParts := parsePattern(path)
-	params := make(map[string]string)
 	root, ok := r.roots[method]
 
 	if !ok {
 		return nil, nil
 	}
 
-	n := root.search(searchParts, 0)
+	n, values := root.search(cleanPath(path))
+	if n == nil {
+		return nil, nil
+	}
 
-	if n != nil {
-		parts := parsePattern(n.pattern)
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
-			} else if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
-				break
-			}
+	// 静态路由没有参数，直接返回 nil，避免分配 map
+	if len(values) == 0 {
+		return n, nil
+	}
+	// search 返回的参数值是从深到浅排列的
+	params := make(map[string]string, len(values))
+	for i, name := range n.paramNames {
+		params[name] = values[len(values)-1-i]
+	}
+	return n, params
+}
+
+// 收集 path 在哪些请求方式下存在路由，用于 405 的 Allow 头和 OPTIONS 应答
+func (r *router) allowed(path string) []string {
+	path = cleanPath(path)
+	allow := make([]string, 0)
+	for method, root := range r.roots {
+		if n, _ := root.search(path); n != nil {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) > 0 {
+		// OPTIONS 总是可用的：没有显式注册时由 router 自动应答
+		if !contains(allow, http.MethodOptions) {
+			allow = append(allow, http.MethodOptions)
 		}
-		return n, params
+		// map 的遍历顺序是随机的，排序后保证 Allow 头稳定
+		sort.Strings(allow)
 	}
+	return allow
+}
 
-	return nil, nil
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
 }
 
 /**
  * 解析请求的路径，查找路由映射表
- * 如果找到，就执行注册的处理方法
- * 如果找不到，返回 404 NOT FOUND
+ * 如果找到，就执行叶子节点上注册好的中间件链和处理方法
+ * 如果找不到，但该路径在其他请求方式下存在：
+ *   - OPTIONS 请求自动返回 204 和 Allow 头
+ *   - 其他请求返回 405 METHOD NOT ALLOWED 和 Allow 头
+ * 否则返回 404 NOT FOUND
+ * 后两种情况会先执行路径所在分组的中间件
  */
-func (r *router) handle(c *Context) {
+func (r *router) handle(c *Context, root *RouterGroup, hostParams map[string]string) {
 	n, params := r.getRoute(c.Method, c.Path)
 	if n != nil {
 		c.Params = params
-		key := c.Method + "-" + n.pattern
-		// 将路由匹配的 Handler 添加到中间件列表中
-		c.handlers = append(c.handlers, r.handlers[key])
+		c.handlers = n.handlers
+		c.group = n.group
+		c.fullPath = n.pattern
+	} else if allow := r.allowed(c.Path); len(allow) > 0 {
+		c.group = c.engine.matchGroup(root, c.Path)
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		handlers := c.group.findHandlers(func(g *RouterGroup) []HandlerFunc { return g.noMethod })
+		if c.Method == http.MethodOptions {
+			handlers = []HandlerFunc{func(c *Context) { c.Status(http.StatusNoContent) }}
+		} else if len(handlers) == 0 {
+			handlers = []HandlerFunc{defaultNoMethod}
+		}
+		c.handlers = c.group.combineHandlers(handlers...)
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		c.group = c.engine.matchGroup(root, c.Path)
+		handlers := c.group.findHandlers(func(g *RouterGroup) []HandlerFunc { return g.noRoute })
+		if len(handlers) == 0 {
+			handlers = []HandlerFunc{defaultNoRoute}
+		}
+		c.handlers = c.group.combineHandlers(handlers...)
+	}
+	// 主机参数与路径参数合并，重名时以路径参数为准
+	if len(hostParams) > 0 {
+		if c.Params == nil {
+			c.Params = hostParams
+		} else {
+			for k, v := range hostParams {
+				if _, ok := c.Params[k]; !ok {
+					c.Params[k] = v
+				}
+			}
+		}
 	}
 	// 依次执行中间件
 	c.Next()
@@ -0,0 +1,188 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// 一条已注册路由的信息，由 engine.Routes() 返回
+type RouteInfo struct {
+	Method      string
+	Host        string // engine.Host 注册的主机 pattern，不属于任何主机时为 ""
+	Path        string // 规范化后的 pattern，例如 /article/:id<int>、/archive/:year/:month?
+	Handler     string // 处理函数（handlers 的最后一个）的函数名
+	HandlerFunc HandlerFunc
+	Name        string    // 通过 Route.Name 设置的路由名，没有设置时为 ""
+	Doc         *RouteDoc // 通过 Route.Doc 设置的文档信息，用于生成 OpenAPI 文档
+}
+
+/**
+ * 注册路由的返回值，用于给路由命名，例如
+ * r.GET("/article/:id", show).Name("article.show")
+ * Any 注册的多个路由共享同一个名字
+ */
+type Route struct {
+	engine *Engine
+	infos  []*RouteInfo
+}
+
+// 给路由命名，之后可以通过 engine.URL 生成它的地址；名字重复时 panic
+func (r *Route) Name(name string) *Route {
+	if name == "" {
+		panic("gee: route name must not be empty")
+	}
+	if existing, ok := r.engine.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("gee: route name %q is already used by %s %s", name, existing.Method, existing.Path))
+	}
+	for _, info := range r.infos {
+		info.Name = name
+	}
+	r.engine.namedRoutes[name] = r.infos[0]
+	return r
+}
+
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// 按注册顺序返回全部路由，可用于打印路由表或者生成 API 文档
+func (engine *Engine) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(engine.routes))
+	for i, info := range engine.routes {
+		routes[i] = *info
+	}
+	return routes
+}
+
+/**
+ * 根据路由名生成地址，params 提供 pattern 中的参数，例如
+ * engine.URL("article.show", map[string]string{"id": "42", "page": "2"}) => /article/42?page=2
+ * 参数值会进行转义，通配参数（*filepath）中的 / 保持不变，没有提供的可选参数会被省略
+ * 参数值不满足约束（例如 :id<int>）时返回错误
+ * pattern 中没有的参数按键的字典序追加为查询参数
+ * 路由名不存在或者缺少 pattern 中的参数时返回错误
+ */
+func (engine *Engine) URL(name string, params map[string]string) (string, error) {
+	info, ok := engine.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("gee: no route named %q", name)
+	}
+	used := make(map[string]bool, len(params))
+	parts := parsePattern(info.Path)
+	segments := make([]string, 0, len(parts))
+loop:
+	for _, part := range parts {
+		switch {
+		case part[0] == ':':
+			key, constraint, optional := parseParam(part)
+			value, ok := params[key]
+			if !ok && optional { // 可选参数只能在末尾，之后的片段都省略
+				break loop
+			}
+			if !ok {
+				return "", fmt.Errorf("gee: route %q requires parameter %q", name, key)
+			}
+			if constraint != "" && !paramMatcher(constraint, info.Path)(value) {
+				return "", fmt.Errorf("gee: parameter %q of route %q does not match <%s>", key, name, constraint)
+			}
+			used[key] = true
+			segments = append(segments, url.PathEscape(value))
+		case part[0] == '*':
+			value, ok := params[part[1:]]
+			if !ok && len(part) > 1 {
+				return "", fmt.Errorf("gee: route %q requires parameter %q", name, part[1:])
+			}
+			used[part[1:]] = true
+			escaped := strings.Split(strings.Trim(value, "/"), "/")
+			for i, s := range escaped {
+				escaped[i] = url.PathEscape(s)
+			}
+			segments = append(segments, strings.Join(escaped, "/"))
+		default:
+			segments = append(segments, part)
+		}
+	}
+
+	u := "/" + strings.Join(segments, "/")
+	query := url.Values{}
+	for key, value := range params {
+		if !used[key] {
+			query.Set(key, value)
+		}
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode() // Encode 按键排序
+	}
+	return u, nil
+}
+
+// 与 URL 相同，出错时 panic，适合在模板函数或初始化代码中使用
+func (engine *Engine) MustURL(name string, params map[string]string) string {
+	u, err := engine.URL(name, params)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+/**
+ * 以文本形式输出每种请求方式的前缀树，便于排查路由匹配问题，例如
+ * GET
+ * └── /
+ *     ├── h
+ *     │   ├── ello  => /hello
+ *     │   └── i/
+ *     │       └── :name  => /hi/:name
+ */
+func (engine *Engine) RouteTree() string {
+	var b strings.Builder
+	engine.router.dump(&b)
+	// engine.Host 注册的主机各有一棵前缀树，输出在主机 pattern 之下
+	for _, vh := range engine.hosts {
+		b.WriteString("host " + vh.pattern + "\n")
+		vh.group.router.dump(&b)
+	}
+	return b.String()
+}
+
+func (r *router) dump(b *strings.Builder) {
+	methods := make([]string, 0, len(r.roots))
+	for method := range r.roots {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		b.WriteString(method + "\n")
+		r.roots[method].dump(b, "")
+	}
+}
+
+/**
+ * 调试用的 handler，输出路由表和前缀树，例如
+ * r.GET("/debug/routes", gee.DebugRoutes())
+ * 不要在生产环境中对外暴露
+ */
+func DebugRoutes() HandlerFunc {
+	return func(c *Context) {
+		if c.engine == nil {
+			c.Fail(http.StatusInternalServerError, "no engine")
+			return
+		}
+		var b strings.Builder
+		for _, route := range c.engine.Routes() {
+			fmt.Fprintf(&b, "%-7s %-30s %s", route.Method, route.Path, route.Handler)
+			if route.Name != "" {
+				fmt.Fprintf(&b, " (%s)", route.Name)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(c.engine.RouteTree())
+		c.String(http.StatusOK, "%s", b.String())
+	}
+}
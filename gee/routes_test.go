@@ -0,0 +1,73 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func showArticle(c *Context) {}
+
+func TestRoutesAndURL(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.GET("/articles/:id", showArticle).Name("article.show")
+	api.POST("/articles", func(c *Context) {})
+	r.GET("/assets/*filepath", func(c *Context) {}).Name("assets")
+
+	routes := r.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expected 3 routes, got %d", len(routes))
+	}
+	first := routes[0]
+	if first.Method != http.MethodGet || first.Path != "/api/articles/:id" ||
+		first.Handler != "gee.showArticle" || first.Name != "article.show" {
+		t.Fatalf("unexpected route info %+v", first)
+	}
+
+	cases := []struct {
+		name   string
+		params map[string]string
+		expect string
+	}{
+		{"article.show", map[string]string{"id": "42"}, "/api/articles/42"},
+		{"article.show", map[string]string{"id": "a b", "page": "2"}, "/api/articles/a%20b?page=2"},
+		{"assets", map[string]string{"filepath": "css/main.css"}, "/assets/css/main.css"},
+	}
+	for _, c := range cases {
+		u, err := r.URL(c.name, c.params)
+		if err != nil || u != c.expect {
+			t.Fatalf("URL(%s, %v) = %q, %v; expected %q", c.name, c.params, u, err, c.expect)
+		}
+	}
+	if _, err := r.URL("article.show", nil); err == nil {
+		t.Fatal("expected an error for a missing parameter")
+	}
+	if _, err := r.URL("missing", nil); err == nil {
+		t.Fatal("expected an error for an unknown route name")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a duplicate route name")
+		}
+	}()
+	r.GET("/other", func(c *Context) {}).Name("assets")
+}
+
+func TestDebugRoutes(t *testing.T) {
+	r := New()
+	r.GET("/hello", func(c *Context) {})
+	r.GET("/hi/:name", func(c *Context) {})
+	r.GET("/debug/routes", DebugRoutes())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/routes", nil))
+	body := w.Body.String()
+	for _, s := range []string{"GET     /hi/:name", ":name  => /hi/:name", "ello  => /hello"} {
+		if !strings.Contains(body, s) {
+			t.Fatalf("expected %q in:\n%s", s, body)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/**
+ * 根据 Engine 上的配置创建 http.Server
+ * Run 系列方法都会阻塞，直到：
+ * 1. 收到 SIGINT/SIGTERM：停止接受新连接，等待进行中的请求处理完（最多 ShutdownTimeout），然后返回 nil
+ * 2. 其他地方调用了 engine.Shutdown：返回 nil，由 Shutdown 的调用者等待连接排空
+ * 3. 服务启动或运行出错：返回该错误
+ */
+func (engine *Engine) newServer(addr string) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           engine,
+		ReadTimeout:       engine.ReadTimeout,
+		ReadHeaderTimeout: engine.ReadHeaderTimeout,
+		WriteTimeout:      engine.WriteTimeout,
+		IdleTimeout:       engine.IdleTimeout,
+		MaxHeaderBytes:    engine.MaxHeaderBytes,
+	}
+	if engine.UseH2C {
+		enableH2C(srv)
+	}
+	return srv
+}
+
+func (engine *Engine) Run(addr string) (err error) {
+	srv := engine.newServer(addr)
+	return engine.serve(srv, srv.ListenAndServe)
+}
+
+// HTTPS，net/http 会自动通过 ALPN 协商 HTTP/2
+func (engine *Engine) RunTLS(addr string, certFile string, keyFile string) (err error) {
+	srv := engine.newServer(addr)
+	return engine.serve(srv, func() error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+}
+
+// 监听 Unix Domain Socket，退出时删除 socket 文件
+func (engine *Engine) RunUnix(file string) (err error) {
+	listener, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	return engine.RunListener(listener)
+}
+
+// 使用调用者创建的 listener，例如 systemd 传入的 socket 或测试中的随机端口
+func (engine *Engine) RunListener(listener net.Listener) (err error) {
+	srv := engine.newServer(listener.Addr().String())
+	return engine.serve(srv, func() error {
+		return srv.Serve(listener)
+	})
+}
+
+func (engine *Engine) serve(srv *http.Server, serve func() error) error {
+	engine.trackServer(srv, true)
+	defer engine.trackServer(srv, false)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- serve()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) { // engine.Shutdown 被调用
+			return nil
+		}
+		return err
+	case sig := <-quit:
+		log.Printf("Received %s, shutting down server on %s", sig, srv.Addr)
+		ctx := context.Background()
+		if engine.ShutdownTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, engine.ShutdownTimeout)
+			defer cancel()
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+		<-errCh
+		return nil
+	}
+}
+
+func (engine *Engine) trackServer(srv *http.Server, add bool) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if engine.servers == nil {
+		engine.servers = make(map[*http.Server]struct{})
+	}
+	if add {
+		engine.servers[srv] = struct{}{}
+	} else {
+		delete(engine.servers, srv)
+	}
+}
+
+/**
+ * 优雅退出：关闭所有 Run 系列方法启动的服务，停止接受新连接，
+ * 等待进行中的请求处理完成，或者 ctx 超时
+ * 被 Hijack 的连接（例如 WebSocket）不会被等待
+ */
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	engine.mu.Lock()
+	servers := make([]*http.Server, 0, len(engine.servers))
+	for srv := range engine.servers {
+		servers = append(servers, srv)
+	}
+	engine.mu.Unlock()
+
+	var firstErr error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
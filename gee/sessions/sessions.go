@@ -0,0 +1,291 @@
+/**
+ * sessions 提供基于 cookie 的会话中间件，会话数据保存在可替换的 Store 中，例如
+ * r.Use(sessions.Sessions(sessions.NewMemoryStore()))
+ * r.POST("/login", func(c *gee.Context) {
+ *     s := sessions.Default(c)
+ *     s.RotateID() // 登录后更换会话 ID，防止会话固定攻击
+ *     s.Set("user", "tom")
+ *     s.AddFlash("welcome back")
+ *     c.JSON(http.StatusOK, gee.H{"user": "tom"})
+ * })
+ *
+ * 会话在响应头发出之前自动保存，handler 不需要调用 Save
+ * 会话数据使用 JSON 编码，因此数字读出来是 float64，可以使用 GetInt 等方法转换
+ */
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gee"
+)
+
+// 当前请求的 *Session 在 c.Keys 中的 key
+const SessionKey = "Session"
+
+type Config struct {
+	// cookie 的名字，默认为 session
+	Name string
+	// 会话的有效期，同时作为 cookie 的 MaxAge 和 Store 中数据的过期时间，默认为 24 小时
+	MaxAge time.Duration
+	// cookie 的 Path、Domain、Secure、SameSite，MaxAge 字段会被忽略
+	Cookie gee.CookieOptions
+}
+
+func Sessions(store Store) gee.HandlerFunc {
+	return SessionsWithConfig(store, Config{})
+}
+
+func SessionsWithConfig(store Store, cfg Config) gee.HandlerFunc {
+	if cfg.Name == "" {
+		cfg.Name = "session"
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 24 * time.Hour
+	}
+	return func(c *gee.Context) {
+		s := &Session{store: store, cfg: &cfg, c: c}
+		c.Set(SessionKey, s)
+		// 在响应头发出之前保存会话，这样 handler 写入 body 之后 Set-Cookie 仍然有效
+		w := &sessionWriter{ResponseWriter: c.Writer, s: s}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if !c.Writer.Written() {
+			s.autoSave()
+		} else if s.dirty() {
+			c.Error(errors.New("sessions: session was modified after the response was written"))
+		}
+	}
+}
+
+// 返回当前请求的会话，没有使用 Sessions 中间件时 panic
+func Default(c *gee.Context) *Session {
+	return gee.MustGetAs[*Session](c, SessionKey)
+}
+
+type sessionData struct {
+	Values  map[string]interface{} `json:"v,omitempty"`
+	Flashes []interface{}          `json:"f,omitempty"`
+}
+
+/**
+ * 一个请求中的会话，第一次访问时才从 Store 读取
+ * 客户端提交的会话 ID 无效或已过期时开始一个新会话，并在保存时生成新的 ID，
+ * 不会沿用客户端提供的 ID
+ */
+type Session struct {
+	store Store
+	cfg   *Config
+	c     *gee.Context
+
+	id        string
+	data      sessionData
+	loaded    bool
+	modified  bool
+	rotate    bool
+	destroyed bool
+}
+
+func (s *Session) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	id, err := s.c.Cookie(s.cfg.Name)
+	if err != nil || id == "" {
+		return
+	}
+	b, err := s.store.Load(id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			s.c.Error(err)
+		}
+		return
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		s.c.Error(err)
+		return
+	}
+	s.id = id
+}
+
+// 会话 ID，新会话在保存之前为 ""
+func (s *Session) ID() string {
+	s.load()
+	return s.id
+}
+
+// 是否是本次请求新建的会话
+func (s *Session) IsNew() bool {
+	s.load()
+	return s.id == ""
+}
+
+func (s *Session) Get(key string) interface{} {
+	s.load()
+	return s.data.Values[key]
+}
+
+func (s *Session) GetString(key string) string {
+	v, _ := s.Get(key).(string)
+	return v
+}
+
+// 读取数字，JSON 解码得到的 float64 会被转换为 int
+func (s *Session) GetInt(key string) int {
+	switch v := s.Get(key).(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func (s *Session) GetBool(key string) bool {
+	v, _ := s.Get(key).(bool)
+	return v
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.load()
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]interface{})
+	}
+	s.data.Values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	s.load()
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.modified = true
+	}
+}
+
+// 清空会话中的数据，会话 ID 保持不变
+func (s *Session) Clear() {
+	s.load()
+	s.data = sessionData{}
+	s.modified = true
+}
+
+// 添加一条 flash 消息，它会保留到下一次调用 Flashes 为止，通常用于重定向之后显示提示
+func (s *Session) AddFlash(value interface{}) {
+	s.load()
+	s.data.Flashes = append(s.data.Flashes, value)
+	s.modified = true
+}
+
+// 取出并清空所有 flash 消息
+func (s *Session) Flashes() []interface{} {
+	s.load()
+	flashes := s.data.Flashes
+	if len(flashes) > 0 {
+		s.data.Flashes = nil
+		s.modified = true
+	}
+	return flashes
+}
+
+/**
+ * 保存时更换会话 ID 并删除旧的会话，数据保持不变
+ * 应在登录、提升权限之后调用，防止会话固定攻击
+ */
+func (s *Session) RotateID() {
+	s.load()
+	s.rotate = true
+}
+
+// 删除会话和 cookie
+func (s *Session) Destroy() {
+	s.load()
+	s.destroyed = true
+}
+
+func (s *Session) dirty() bool {
+	return s.modified || s.rotate || s.destroyed
+}
+
+/**
+ * 立即保存会话并设置 cookie，必须在写入响应之前调用
+ * 通常不需要手动调用，中间件会在响应头发出之前自动保存
+ */
+func (s *Session) Save() error {
+	if !s.dirty() {
+		return nil
+	}
+	if s.c.Writer.Written() {
+		return errors.New("sessions: cannot save the session after the response was written")
+	}
+	opts := s.cfg.Cookie
+	if s.destroyed {
+		if s.id != "" {
+			if err := s.store.Delete(s.id); err != nil {
+				return err
+			}
+		}
+		s.c.DeleteCookie(s.cfg.Name, opts)
+		s.id, s.data = "", sessionData{}
+		s.modified, s.rotate, s.destroyed = false, false, false
+		return nil
+	}
+
+	b, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	id := s.id
+	if s.rotate && id != "" {
+		if err := s.store.Delete(id); err != nil {
+			return err
+		}
+		id = ""
+	}
+	id, err = s.store.Save(id, b, s.cfg.MaxAge)
+	if err != nil {
+		return err
+	}
+	s.id = id
+	s.modified, s.rotate = false, false
+	opts.MaxAge = int(s.cfg.MaxAge / time.Second)
+	s.c.SetCookie(s.cfg.Name, id, opts)
+	return nil
+}
+
+func (s *Session) autoSave() {
+	if err := s.Save(); err != nil {
+		s.c.Error(err)
+	}
+}
+
+// 第一次写入响应之前保存会话
+type sessionWriter struct {
+	gee.ResponseWriter
+	s *Session
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	if !w.Written() {
+		w.s.autoSave()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *sessionWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.s.autoSave()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *sessionWriter) Flush() {
+	if !w.Written() {
+		w.s.autoSave()
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,134 @@
+package sessions
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gee"
+	"gee/geetest"
+)
+
+func newEngine(store Store) *gee.Engine {
+	r := gee.New()
+	r.Use(Sessions(store))
+	r.POST("/login", func(c *gee.Context) {
+		s := Default(c)
+		s.RotateID()
+		s.Set("user", "tom")
+		s.Set("visits", 1)
+		s.AddFlash("welcome back")
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/me", func(c *gee.Context) {
+		s := Default(c)
+		if s.IsNew() {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		s.Set("visits", s.GetInt("visits")+1)
+		c.JSON(http.StatusOK, gee.H{"user": s.GetString("user"), "visits": s.GetInt("visits"), "flashes": s.Flashes()})
+	})
+	r.POST("/logout", func(c *gee.Context) {
+		Default(c).Destroy()
+	})
+	return r
+}
+
+// 模拟 GeeCache：读取经过一个只会加载一次的缓存，写入直接进入数据源
+type fakeCache struct {
+	mu     sync.Mutex
+	source map[string][]byte
+	cached map[string][]byte
+}
+
+func (f *fakeCache) Get(key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v, ok := f.cached[key]; ok {
+		return v, nil
+	}
+	v, ok := f.source[key]
+	if !ok {
+		return nil, fmt.Errorf("load %s: %w", key, ErrNotFound)
+	}
+	f.cached[key] = v
+	return v, nil
+}
+
+func (f *fakeCache) put(key string, value []byte) error {
+	f.mu.Lock()
+	f.source[key] = value
+	f.mu.Unlock()
+	return nil
+}
+
+func TestSessions(t *testing.T) {
+	cache := &fakeCache{source: map[string][]byte{}, cached: map[string][]byte{}}
+	stores := map[string]Store{
+		"cookie": NewCookieStore("secret"),
+		"memory": NewMemoryStore(),
+		"cache":  NewCacheStore(cache, cache.put),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			client := geetest.New(newEngine(store))
+			client.GET("/me").Expect(t).Status(http.StatusUnauthorized)
+
+			// 客户端伪造的会话 ID 不会被沿用
+			forged := &http.Cookie{Name: "session", Value: "forged"}
+			resp := client.POST("/login").WithCookie(forged).Do()
+			resp.Expect(t).Status(http.StatusOK).Body("ok")
+			cookie := resp.Result().Cookies()[0]
+			if cookie.Value == "forged" || cookie.MaxAge != 86400 || !cookie.HttpOnly {
+				t.Fatalf("unexpected session cookie %+v", cookie)
+			}
+
+			client.GET("/me").Expect(t).Status(http.StatusOK).
+				JSON(gee.H{"user": "tom", "visits": 2, "flashes": []string{"welcome back"}})
+			client.GET("/me").Expect(t).Status(http.StatusOK).
+				JSONPath("$.visits", 3).
+				JSONPath("$.flashes", nil)
+
+			client.POST("/logout").Expect(t).Status(http.StatusOK)
+			client.GET("/me").Expect(t).Status(http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestRotateID(t *testing.T) {
+	store := NewMemoryStore()
+	client := geetest.New(newEngine(store))
+	first := client.POST("/login").Do().Result().Cookies()[0].Value
+	second := client.POST("/login").Do().Result().Cookies()[0].Value
+	if first == second {
+		t.Fatal("session ID should change on login")
+	}
+	if _, err := store.Load(first); err != ErrNotFound {
+		t.Fatalf("old session should be deleted, got %v", err)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expected 1 session, got %d", store.Len())
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	id, _ := store.Save("", []byte(`{}`), time.Hour)
+	if _, err := store.Load(id); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	if _, err := store.Load(id); err != ErrNotFound {
+		t.Fatalf("expired session should not be found, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	store.Save("", []byte(`{}`), time.Hour)
+	if store.Len() != 1 {
+		t.Fatalf("expired sessions should be swept, got %d", store.Len())
+	}
+}
@@ -0,0 +1,236 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gee"
+)
+
+// 会话不存在或已过期，Store.Load 返回这个错误时中间件会开始一个新会话
+var ErrNotFound = errors.New("sessions: session not found")
+
+/**
+ * 会话数据的存储，data 是编码后的会话数据
+ * Save 的 id 为空时创建新会话，返回的 ID 会写入 cookie；
+ * 不同的 Store 可以在每次保存时返回新的 ID（例如 CookieStore 返回的就是数据本身）
+ */
+type Store interface {
+	Load(id string) (data []byte, err error)
+	Save(id string, data []byte, ttl time.Duration) (string, error)
+	Delete(id string) error
+}
+
+// 生成 256 位的随机会话 ID
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 带过期时间的会话数据，CookieStore 和 CacheStore 保存的就是它的 JSON
+type envelope struct {
+	Expires int64           `json:"e"`
+	Data    json.RawMessage `json:"d"`
+}
+
+func seal(data []byte, ttl time.Duration, now time.Time) ([]byte, error) {
+	return json.Marshal(envelope{Expires: now.Add(ttl).Unix(), Data: data})
+}
+
+func open(b []byte, now time.Time) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, err
+	}
+	if now.Unix() >= env.Expires {
+		return nil, ErrNotFound
+	}
+	return env.Data, nil
+}
+
+/**
+ * 把会话数据加密后整个保存在 cookie 中，服务端不保存任何状态
+ * 加密数据中带有过期时间，过期的 cookie 即使被重放也无效
+ * 缺点是 Delete 无法让已经发出的 cookie 失效，而且数据不能超过 cookie 的大小限制（约 4KB）
+ */
+type CookieStore struct {
+	codec *gee.CookieCodec
+	now   func() time.Time
+}
+
+// secrets 的用法与 engine.SetCookieSecrets 相同，第一个用于加密，其余的只用于解密
+func NewCookieStore(secrets ...string) *CookieStore {
+	return &CookieStore{codec: gee.NewCookieCodec(secrets...), now: time.Now}
+}
+
+// 作为 AES-GCM 的附加数据，防止其他加密 cookie 被当作会话使用
+const cookieStoreName = "gee-session"
+
+// 浏览器对单个 cookie 的大小限制
+const maxCookieSize = 4096
+
+func (s *CookieStore) Load(id string) ([]byte, error) {
+	b, err := s.codec.Decrypt(cookieStoreName, id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return open([]byte(b), s.now())
+}
+
+func (s *CookieStore) Save(_ string, data []byte, ttl time.Duration) (string, error) {
+	b, err := seal(data, ttl, s.now())
+	if err != nil {
+		return "", err
+	}
+	id, err := s.codec.Encrypt(cookieStoreName, string(b))
+	if err != nil {
+		return "", err
+	}
+	if len(id) > maxCookieSize {
+		return "", fmt.Errorf("sessions: encoded session is %d bytes, exceeds the cookie limit of %d", len(id), maxCookieSize)
+	}
+	return id, nil
+}
+
+func (s *CookieStore) Delete(string) error {
+	return nil
+}
+
+/**
+ * 保存在进程内存中的会话，适合单实例部署和测试
+ * 过期的会话在读取时视为不存在，并在保存时顺带清理，清理最多每分钟一次
+ */
+type MemoryStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memorySession), now: time.Now}
+}
+
+func (s *MemoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !s.now().Before(session.expires) {
+		return nil, ErrNotFound
+	}
+	return session.data, nil
+}
+
+func (s *MemoryStore) Save(id string, data []byte, ttl time.Duration) (string, error) {
+	if id == "" {
+		var err error
+		if id, err = newID(); err != nil {
+			return "", err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sessions[id] = memorySession{data: data, expires: now.Add(ttl)}
+	if now.Sub(s.lastSweep) >= time.Minute {
+		s.lastSweep = now
+		for k, session := range s.sessions {
+			if !now.Before(session.expires) {
+				delete(s.sessions, k)
+			}
+		}
+	}
+	return id, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// 当前保存的会话数量，包括还没有被清理的过期会话
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+/**
+ * 读穿透的缓存，例如 GeeCache 的 Group：未命中时由它的 Getter 从数据源加载
+ * gee 不依赖 geecache，它的 Get 返回 ByteView，用 CacheFunc 做一层适配即可：
+ * group := geecache.NewGroup("sessions", 64<<20, geecache.GetterFunc(db.Get))
+ * cache := sessions.CacheFunc(func(key string) ([]byte, error) {
+ *     v, err := group.Get(key)
+ *     return v.ByteSlice(), err
+ * })
+ * 数据源中不存在 key 时，Getter 应返回（包装了）ErrNotFound 的错误，其他错误会被记录到 c.Errors
+ */
+type Cache interface {
+	Get(key string) ([]byte, error)
+}
+
+type CacheFunc func(key string) ([]byte, error)
+
+func (f CacheFunc) Get(key string) ([]byte, error) {
+	return f(key)
+}
+
+/**
+ * 基于读穿透缓存的会话存储，写入数据源，读取经过缓存（例如 GeeCache 的分布式节点）
+ * GeeCache 只能读取，不支持修改和删除已经缓存的值，因此这里的每个 key 只写一次：
+ * 每次保存都写入一个新的 key 并更新 cookie，数据中带有过期时间
+ * 旧的 key 在过期或被 LRU 淘汰之前仍然可以读到旧的数据，所以 MaxAge 不宜过长
+ */
+type CacheStore struct {
+	cache Cache
+	put   func(key string, value []byte) error
+	now   func() time.Time
+}
+
+// put 把数据写入 cache 的 Getter 读取的数据源
+func NewCacheStore(cache Cache, put func(key string, value []byte) error) *CacheStore {
+	return &CacheStore{cache: cache, put: put, now: time.Now}
+}
+
+func (s *CacheStore) Load(id string) ([]byte, error) {
+	b, err := s.cache.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return open(b, s.now())
+}
+
+func (s *CacheStore) Save(_ string, data []byte, ttl time.Duration) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	b, err := seal(data, ttl, s.now())
+	if err != nil {
+		return "", err
+	}
+	if err := s.put(id, b); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// 缓存中的值无法删除，过期时间到了之后自然失效
+func (s *CacheStore) Delete(string) error {
+	return nil
+}
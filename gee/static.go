@@ -0,0 +1,226 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+/**
+ * 静态文件服务的配置，零值表示：
+ * 不设置 Cache-Control、不允许列目录、目录下的 index.html 作为首页、没有 SPA 回退、不查找预压缩文件
+ */
+type StaticConfig struct {
+	// 例如 "public, max-age=31536000, immutable"
+	CacheControl string
+	// 目录下没有首页文件时是否列出目录内容
+	Browse bool
+	// 请求目录时返回的首页文件，默认为 index.html
+	Index string
+	// 单页应用的回退文件，例如 index.html：请求的文件不存在且路径没有扩展名时返回它，
+	// 这样前端路由（例如 /app/users/1）刷新页面时不会 404，而缺失的 /app/main.js 仍然返回 404
+	Fallback string
+	// 客户端支持时优先返回同名的 .br/.gz 预压缩文件
+	Precompressed bool
+}
+
+// 将静态文件夹本地根目录 root 映射到路由 relativePath/*filepath
+func (group *RouterGroup) Static(relativePath string, root string, config ...StaticConfig) {
+	group.StaticFS(relativePath, os.DirFS(root), config...)
+}
+
+/**
+ * 将 fs.FS（例如 embed.FS）映射到路由 relativePath/*filepath，同时注册 GET 和 HEAD
+ * 支持 Range 请求、If-None-Match/If-Modified-Since 条件请求
+ * relativePath 本身也会被注册，返回根目录的首页或目录列表（路由会去掉末尾的 /，/static/ 也匹配它）
+ * 文件不存在时通过 c.Error 记录 404 的 *HTTPError，由 engine.ErrorHandler 生成响应
+ */
+func (group *RouterGroup) StaticFS(relativePath string, fsys fs.FS, config ...StaticConfig) {
+	var cfg StaticConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Index == "" {
+		cfg.Index = "index.html"
+	}
+	handler := newStaticHandler(fsys, cfg)
+	urlPattern := path.Join(relativePath, "/*filepath")
+	group.GET(urlPattern, handler)
+	group.HEAD(urlPattern, handler)
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
+
+// 将单个本地文件映射到路由 relativePath
+func (group *RouterGroup) StaticFile(relativePath string, filepath string, config ...StaticConfig) {
+	dir, file := path.Split(filepath)
+	if dir == "" {
+		dir = "."
+	}
+	group.StaticFileFS(relativePath, file, os.DirFS(dir), config...)
+}
+
+// 将 fs.FS 中的单个文件映射到路由 relativePath
+func (group *RouterGroup) StaticFileFS(relativePath string, name string, fsys fs.FS, config ...StaticConfig) {
+	var cfg StaticConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	handler := func(c *Context) {
+		serveStatic(c, fsys, name, cfg)
+	}
+	group.GET(relativePath, handler)
+	group.HEAD(relativePath, handler)
+}
+
+func newStaticHandler(fsys fs.FS, cfg StaticConfig) HandlerFunc {
+	return func(c *Context) {
+		// 得到 fsys 下的相对路径，path.Clean 去掉 ..，fs.FS 的路径不以 / 开头
+		name := strings.TrimPrefix(path.Clean("/"+c.Param("filepath")), "/")
+		if name == "" {
+			name = "."
+		}
+		serveStatic(c, fsys, name, cfg)
+	}
+}
+
+func serveStatic(c *Context, fsys fs.FS, name string, cfg StaticConfig) {
+	info, err := fs.Stat(fsys, name)
+	if err == nil && info.IsDir() {
+		index := path.Join(name, cfg.Index)
+		if indexInfo, indexErr := fs.Stat(fsys, index); indexErr == nil && !indexInfo.IsDir() {
+			name, info = index, indexInfo
+		} else if cfg.Browse {
+			listDirectory(c, fsys, name)
+			return
+		} else {
+			err = fs.ErrNotExist
+		}
+	}
+	if err != nil {
+		if cfg.Fallback == "" || path.Ext(name) != "" {
+			staticNotFound(c)
+			return
+		}
+		name = cfg.Fallback
+		if info, err = fs.Stat(fsys, name); err != nil {
+			staticNotFound(c)
+			return
+		}
+	}
+
+	header := c.Writer.Header()
+	if cfg.CacheControl != "" {
+		header.Set("Cache-Control", cfg.CacheControl)
+	}
+
+	served := name
+	if cfg.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		for _, enc := range []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !acceptsEncoding(c.Req, enc.encoding) {
+				continue
+			}
+			if compressed, err := fs.Stat(fsys, name+enc.ext); err == nil && !compressed.IsDir() {
+				header.Set("Content-Encoding", enc.encoding)
+				served, info = name+enc.ext, compressed
+				break
+			}
+		}
+	}
+
+	f, err := fsys.Open(served)
+	if err != nil {
+		staticNotFound(c)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	if header.Get("ETag") == "" {
+		etag, err := staticETag(info, content)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		header.Set("ETag", etag)
+	}
+	// 按原文件名决定 Content-Type，并处理 Range 和条件请求
+	http.ServeContent(c.Writer, c.Req, path.Base(name), info.ModTime(), content)
+}
+
+// 与没有匹配到路由时的 404 使用同样的格式
+func staticNotFound(c *Context) {
+	c.Error(NewHTTPError(http.StatusNotFound, fmt.Sprintf("file %s not found", c.Path)))
+}
+
+/**
+ * 根据修改时间和大小生成弱 ETag
+ * embed.FS 中文件的修改时间为零值，此时改用内容的 sha256
+ */
+func staticETag(info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`, nil
+}
+
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	for _, spec := range parseAccept(req.Header.Get("Accept-Encoding")) {
+		if spec.mimeType == encoding && spec.q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func listDirectory(c *Context, fsys fs.FS, name string) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		c.Error(err)
+		c.String(http.StatusInternalServerError, "Error reading directory")
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	base := strings.TrimSuffix(c.Path, "/") + "/"
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		u := url.URL{Path: base + entryName}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", u.EscapedPath(), html.EscapeString(entryName))
+	}
+	b.WriteString("</pre>\n")
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer.Write([]byte(b.String()))
+}
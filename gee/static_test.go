@@ -0,0 +1,106 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":      {Data: []byte("<app>"), ModTime: time.Unix(1, 0)},
+		"js/app.js":       {Data: []byte("console.log(1)"), ModTime: time.Unix(1, 0)},
+		"js/app.js.gz":    {Data: []byte("gzipped"), ModTime: time.Unix(1, 0)},
+		"docs/readme.txt": {Data: []byte("0123456789"), ModTime: time.Unix(1, 0)},
+	}
+	r := New()
+	r.StaticFS("/app", fsys, StaticConfig{
+		CacheControl:  "public, max-age=60",
+		Fallback:      "index.html",
+		Precompressed: true,
+		Browse:        true,
+	})
+
+	do := func(path string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/app/js/app.js", "Accept-Encoding", "gzip, br;q=0")
+	if w.Body.String() != "gzipped" || w.Header().Get("Content-Encoding") != "gzip" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+		t.Fatalf("should serve precompressed file, got %q %v", w.Body.String(), w.Header())
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+
+	w = do("/app/js/app.js")
+	etag := w.Header().Get("ETag")
+	if w.Body.String() != "console.log(1)" || etag == "" {
+		t.Fatalf("unexpected response %q etag=%q", w.Body.String(), etag)
+	}
+	if w = do("/app/js/app.js", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Fatalf("expect 304, got %d", w.Code)
+	}
+
+	if w = do("/app/docs/readme.txt", "Range", "bytes=2-4"); w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Fatalf("expect 206 \"234\", got %d %q", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/app", http.StatusOK, "<app>"},
+		{"/app/users/1", http.StatusOK, "<app>"}, // 前端路由回退到首页
+		{"/app/js/missing.js", http.StatusNotFound, ""},
+		{"/app/../../etc/passwd.txt", http.StatusNotFound, ""}, // 不能跳出 fsys
+		{"/app/docs", http.StatusOK, "<a href=\"/app/docs/readme.txt\">readme.txt</a>"},
+	}
+	for _, c := range cases {
+		w := do(c.path)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.body) {
+			t.Fatalf("%s: expect %d %q, got %d %q", c.path, c.code, c.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestStaticRoot(t *testing.T) {
+	r := New()
+	r.StaticFS("/site", fstest.MapFS{"index.html": {Data: []byte("home")}})
+	r.StaticFS("/files", fstest.MapFS{"a.txt": {Data: []byte("a")}}, StaticConfig{Browse: true})
+	r.StaticFS("/empty", fstest.MapFS{"a.txt": {Data: []byte("a")}})
+
+	cases := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/site", http.StatusOK, "home"},
+		{"/site/", http.StatusOK, "home"},
+		{"/files/", http.StatusOK, `<a href="/files/a.txt">a.txt</a>`},
+		// 不允许列目录时，根目录和不存在的文件一样返回 problem+json 的 404
+		{"/empty/", http.StatusNotFound, `"detail":"file /empty/ not found"`},
+		{"/site/missing.js", http.StatusNotFound, `"detail":"file /site/missing.js not found"`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.body) {
+			t.Fatalf("%s: expect %d %q, got %d %q", c.path, c.code, c.body, w.Code, w.Body.String())
+		}
+		if c.code == http.StatusNotFound && w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Fatalf("%s: 404 should use problem+json, got %q", c.path, w.Header().Get("Content-Type"))
+		}
+	}
+}
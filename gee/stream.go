@@ -0,0 +1,83 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/**
+ * 流式响应，每次调用 step 之后都会 Flush，让客户端立即收到数据
+ * step 返回 false 表示数据已经写完
+ * 客户端断开连接时，请求的 context 会被取消，Stream 随即返回 true，
+ * 长时间运行的 handler 应据此退出，例如
+ * c.Stream(func(w io.Writer) bool {
+ *     msg, ok := <-ch
+ *     if ok { c.SSEvent("progress", msg) }
+ *     return ok
+ * })
+ */
+func (c *Context) Stream(step func(w io.Writer) bool) (clientGone bool) {
+	done := c.Req.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+/**
+ * 发送一条 Server-Sent Event，并立即 Flush
+ * name 为空时省略 event 字段（浏览器端按 message 事件处理）
+ * data 为 string/[]byte 时原样发送，其他类型编码为 JSON
+ * 多行数据会被拆成多个 data 字段，符合 text/event-stream 规范
+ */
+func (c *Context) SSEvent(name string, data interface{}) {
+	if !c.Writer.Written() {
+		header := c.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		// 禁止 nginx 等反向代理缓冲响应
+		header.Set("X-Accel-Buffering", "no")
+	}
+
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		payload = string(b)
+	}
+
+	var event strings.Builder
+	if name != "" {
+		fmt.Fprintf(&event, "event: %s\n", sanitizeEventField(name))
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(payload, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(&event, "data: %s\n", line)
+	}
+	event.WriteString("\n")
+
+	c.Writer.Write([]byte(event.String()))
+	c.Writer.Flush()
+}
+
+// 事件名中的换行会破坏事件的边界，直接去掉
+func sanitizeEventField(s string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(s)
+}
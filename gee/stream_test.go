@@ -0,0 +1,61 @@
+package gee
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSSEvent(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Context) {
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			i++
+			c.SSEvent("progress", H{"step": i})
+			return i < 2
+		})
+		c.SSEvent("", "done\nbye")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected Content-Type %q", w.Header().Get("Content-Type"))
+	}
+	// Connection 是逐跳头部，HTTP/2 中不允许出现
+	if w.Header().Get("Connection") != "" {
+		t.Fatalf("unexpected Connection header %q", w.Header().Get("Connection"))
+	}
+	expect := "event: progress\ndata: {\"step\":1}\n\n" +
+		"event: progress\ndata: {\"step\":2}\n\n" +
+		"data: done\ndata: bye\n\n"
+	if w.Body.String() != expect || !w.Flushed {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestStreamClientGone(t *testing.T) {
+	r := New()
+	gone := make(chan bool, 1)
+	r.GET("/stream", func(c *Context) {
+		gone <- c.Stream(func(w io.Writer) bool {
+			time.Sleep(10 * time.Millisecond)
+			return true // 永远不会主动结束
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil).WithContext(ctx)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+	}()
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if !<-gone {
+		t.Fatal("Stream should report client disconnect")
+	}
+}
@@ -0,0 +1,274 @@
+package gee
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/**
+ * 一组模板，支持布局（layout）和公共片段（partial）
+ * 每个页面都会和全部布局、片段一起单独解析，因此不同页面可以各自定义同名的 block，例如
+ *
+ * layouts/base.html:  {{define "base"}}<html>{{block "content" .}}{{end}}</html>{{end}}
+ * partials/nav.html:  {{define "nav"}}<nav>...</nav>{{end}}
+ * pages/index.html:   {{define "content"}}{{template "nav" .}}<h1>{{.title}}</h1>{{end}}
+ *
+ * set := &gee.TemplateSet{
+ *     FS:       os.DirFS("templates"), // 或者 embed.FS
+ *     Layouts:  []string{"layouts/*.html"},
+ *     Partials: []string{"partials/*.html"},
+ *     Pages:    []string{"pages/*.html"},
+ *     Layout:   "base",
+ * }
+ * engine.AddTemplateSet("web", set)
+ * engine.Group("/web").UseTemplateSet("web")
+ * c.HTML(200, "pages/index.html", gee.H{"title": "gee"})
+ *
+ * 页面名是页面文件在 FS 中的路径；Layout 不为空且页面中存在该模板时执行布局，否则直接执行页面
+ * Debug 为 true 时每次渲染前都会检查文件是否有增删改，有变化就重新解析，修改模板无需重启
+ */
+type TemplateSet struct {
+	FS       fs.FS
+	Layouts  []string // 布局文件的 glob，例如 layouts/*.html
+	Partials []string // 公共片段的 glob
+	Pages    []string // 页面文件的 glob
+	Layout   string   // 渲染页面时执行的布局模板名
+	Funcs    template.FuncMap
+	Debug    bool
+
+	mu        sync.RWMutex
+	baseFuncs template.FuncMap // engine.SetFuncMap 设置的函数，优先级低于 Funcs
+	pages     map[string]*template.Template
+	signature string // Debug 模式下用于判断文件是否有变化
+}
+
+// 解析全部模板，模板有语法错误时返回错误，之前解析成功的模板保持不变
+func (s *TemplateSet) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// 调用者需要持有写锁
+func (s *TemplateSet) load() error {
+	if s.FS == nil {
+		return errors.New("gee: TemplateSet.FS is nil")
+	}
+	layouts, err := s.glob(s.Layouts)
+	if err != nil {
+		return err
+	}
+	partials, err := s.glob(s.Partials)
+	if err != nil {
+		return err
+	}
+	pages, err := s.glob(s.Pages)
+	if err != nil {
+		return err
+	}
+
+	funcs := template.FuncMap{}
+	for k, v := range s.baseFuncs {
+		funcs[k] = v
+	}
+	for k, v := range s.Funcs {
+		funcs[k] = v
+	}
+	base := template.New("").Funcs(funcs)
+	for _, file := range append(layouts, partials...) {
+		if err := s.parseFile(base, file); err != nil {
+			return err
+		}
+	}
+
+	parsed := make(map[string]*template.Template, len(pages))
+	for _, page := range pages {
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := s.parseFile(t, page); err != nil {
+			return err
+		}
+		parsed[page] = t
+	}
+
+	signature, err := s.computeSignature(append(append(layouts, partials...), pages...))
+	if err != nil {
+		return err
+	}
+	s.pages = parsed
+	s.signature = signature
+	return nil
+}
+
+func (s *TemplateSet) glob(patterns []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(s.FS, pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *TemplateSet) parseFile(t *template.Template, file string) error {
+	content, err := fs.ReadFile(s.FS, file)
+	if err != nil {
+		return err
+	}
+	if _, err := t.New(file).Parse(string(content)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 文件名、大小和修改时间组成的签名，embed.FS 的修改时间恒为零值，不会触发重新解析
+func (s *TemplateSet) computeSignature(files []string) (string, error) {
+	var b strings.Builder
+	for _, file := range files {
+		info, err := fs.Stat(s.FS, file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+func (s *TemplateSet) reloadIfChanged() error {
+	s.mu.RLock()
+	signature := s.signature
+	s.mu.RUnlock()
+
+	files, err := s.glob(append(append(append([]string{}, s.Layouts...), s.Partials...), s.Pages...))
+	if err != nil {
+		return err
+	}
+	current, err := s.computeSignature(files)
+	if err != nil {
+		return err
+	}
+	if current == signature {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.signature != signature { // 其他请求已经重新解析过了
+		return nil
+	}
+	log.Printf("Templates changed, reloading")
+	return s.load()
+}
+
+// 渲染页面 name，Debug 模式下会先检查模板文件是否有变化
+func (s *TemplateSet) Render(w io.Writer, name string, data interface{}) error {
+	if s.Debug {
+		if err := s.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+	s.mu.RLock()
+	t, ok := s.pages[name]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("gee: template %q is not defined", name)
+	}
+	if s.Layout != "" && t.Lookup(s.Layout) != nil {
+		return t.ExecuteTemplate(w, s.Layout, data)
+	}
+	return t.ExecuteTemplate(w, name, data)
+}
+
+/**
+ * 注册一组命名的模板，并立即解析，模板有错误时返回错误
+ * engine.SetFuncMap 设置的函数对所有模板组生效
+ */
+func (engine *Engine) AddTemplateSet(name string, set *TemplateSet) error {
+	set.mu.Lock()
+	set.baseFuncs = engine.funcMap
+	err := set.load()
+	set.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if engine.templateSets == nil {
+		engine.templateSets = make(map[string]*TemplateSet)
+	}
+	engine.templateSets[name] = set
+	return nil
+}
+
+// 该分组（包括子分组）中的 c.HTML 使用名为 name 的模板组，模板组必须已经注册
+func (group *RouterGroup) UseTemplateSet(name string) {
+	set, ok := group.engine.templateSets[name]
+	if !ok {
+		panic(fmt.Sprintf("gee: template set %q is not registered", name))
+	}
+	group.templateSet = set
+}
+
+// 从当前分组向上查找第一个设置了模板组的分组
+func (group *RouterGroup) findTemplateSet() *TemplateSet {
+	for g := group; g != nil; g = g.parent {
+		if g.templateSet != nil {
+			return g.templateSet
+		}
+	}
+	return nil
+}
+
+/**
+ * 渲染 HTML 模板，按以下顺序查找模板：
+ * 1. 路由所在分组（或其祖先分组）通过 UseTemplateSet 指定的模板组
+ * 2. engine.LoadHTMLGlob 加载的全局模板
+ * 没有可用的模板或渲染失败时返回 500，不会发出一半的页面
+ */
+func (c *Context) HTML(code int, name string, data interface{}) {
+	var buf bytes.Buffer
+	var err error
+	if set := c.group.findTemplateSet(); set != nil {
+		err = set.Render(&buf, name, data)
+	} else if c.engine != nil && c.engine.htmlTemplates != nil {
+		err = c.engine.htmlTemplates.ExecuteTemplate(&buf, name, data)
+	} else {
+		err = fmt.Errorf("gee: no templates loaded to render %q", name)
+	}
+	if err != nil {
+		c.Error(err)
+		c.Fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Status(code)
+	c.Writer.Write(buf.Bytes())
+	if rec, ok := c.Req.Context().Value(templateRecorderKey{}).(TemplateRecorder); ok {
+		rec.RecordTemplate(name, data)
+	}
+}
+
+// 记录 c.HTML 渲染了哪个模板，用于测试，例如 geetest 检查渲染的模板和数据
+type TemplateRecorder interface {
+	RecordTemplate(name string, data interface{})
+}
+
+type templateRecorderKey struct{}
+
+// 返回带有 rec 的 context，使用它的请求每次 c.HTML 渲染成功后都会调用 rec.RecordTemplate
+func WithTemplateRecorder(ctx context.Context, rec TemplateRecorder) context.Context {
+	return context.WithValue(ctx, templateRecorderKey{}, rec)
+}
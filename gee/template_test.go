@@ -0,0 +1,71 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestTemplateSet(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html":  {Data: []byte(`{{define "base"}}<html>{{block "content" .}}{{end}}</html>{{end}}`)},
+		"partials/nav.html":  {Data: []byte(`{{define "nav"}}<nav>{{upper .}}</nav>{{end}}`)},
+		"pages/index.html":   {Data: []byte(`{{define "content"}}{{template "nav" "home"}}<h1>{{.}}</h1>{{end}}`)},
+		"pages/about.html":   {Data: []byte(`{{define "content"}}about{{end}}`)},
+		"admin/console.html": {Data: []byte(`console {{.}}`), ModTime: time.Unix(1, 0)},
+	}
+	r := New()
+	r.SetFuncMap(map[string]interface{}{"upper": func(s string) string { return s + "!" }})
+	if err := r.AddTemplateSet("web", &TemplateSet{
+		FS:       fsys,
+		Layouts:  []string{"layouts/*.html"},
+		Partials: []string{"partials/*.html"},
+		Pages:    []string{"pages/*.html"},
+		Layout:   "base",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddTemplateSet("admin", &TemplateSet{FS: fsys, Pages: []string{"admin/*.html"}, Debug: true}); err != nil {
+		t.Fatal(err)
+	}
+	r.UseTemplateSet("web")
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "pages/index.html", "gee") })
+	r.GET("/about", func(c *Context) { c.HTML(http.StatusOK, "pages/about.html", nil) })
+	admin := r.Group("/admin")
+	admin.UseTemplateSet("admin")
+	admin.GET("/console", func(c *Context) { c.HTML(http.StatusOK, "admin/console.html", "v1") })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	cases := []struct{ path, body string }{
+		{"/", "<html><nav>home!</nav><h1>gee</h1></html>"},
+		{"/about", "<html>about</html>"}, // 每个页面各自定义 content，互不覆盖
+		{"/admin/console", "console v1"},
+	}
+	for _, c := range cases {
+		if w := get(c.path); w.Body.String() != c.body {
+			t.Fatalf("%s: expect %q, got %q", c.path, c.body, w.Body.String())
+		}
+	}
+
+	// Debug 模式下修改模板后无需重启
+	fsys["admin/console.html"] = &fstest.MapFile{Data: []byte(`new console {{.}}`), ModTime: time.Unix(2, 0)}
+	if w := get("/admin/console"); w.Body.String() != "new console v1" {
+		t.Fatalf("template should be reloaded, got %q", w.Body.String())
+	}
+}
+
+func TestHTMLWithoutTemplates(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) { c.HTML(http.StatusOK, "index.html", nil) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expect 500, got %d", w.Code)
+	}
+}
@@ -1,82 +1,223 @@
 package gee
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
+type nodeType uint8
+
+const (
+	static   nodeType = iota // 静态片段，例如 /p/doc
+	param                    // 参数片段，例如 :lang
+	catchAll                 // 通配片段，例如 *filepath
+)
+
+/**
+ * 压缩前缀树（Radix Tree）的节点
+ * 静态片段按公共前缀合并，例如 /hello/b/c 与 /hi/:name 共享 /h 节点
+ * 参数与通配片段总是占据一个完整的路径段，单独作为子节点挂载
+ *
+ * 匹配优先级固定为：静态 > 参数 > 通配，与注册顺序无关
+ * 同一位置可以有多个带约束的参数（:id<int>、:name<alpha>），按注册顺序尝试，
+ * 不带约束的参数至多一个，排在最后
+ * 某个分支匹配失败时会回溯，尝试下一优先级的分支
+ */
 type node struct {
 	pattern string // 待匹配路由，例如 /p/:lang
 	// 只有叶子节点会记录 pattern，非叶子节点 pattern 为 ""
-	part     string  // 路由中的一部分，例如 :lang
-	children []*node // 子节点，例如 [doc, tutorial, intro]
-	isWild   bool    // 是否模糊匹配，part 含有 : 或 * 时为 true
+	part string // 静态节点为压缩后的公共前缀，参数/通配节点为 :lang / *filepath
+	typ  nodeType
+
+	indices  []byte  // 静态子节点 part 的首字节，与 children 一一对应
+	children []*node // 静态子节点
+	wilds    []*node // 参数子节点，带约束的在前，不带约束的至多一个且在最后
+	catchAll *node   // 通配子节点，同一位置只允许一个通配名
+
+	constraint string            // 参数节点的约束，例如 int、[a-z]+
+	match      func(string) bool // constraint 对应的匹配函数，没有约束时为 nil
+
+	paramNames []string      // 叶子节点记录 pattern 中的参数名（按出现顺序），避免每次请求重新解析 pattern
+	handlers   []HandlerFunc // 叶子节点记录注册时就组合好的中间件链和处理函数
+	group      *RouterGroup  // 叶子节点记录注册该路由的分组
 }
 
-// 第一个匹配成功的节点，用于插入
-func (n *node) matchChild(part string) *node {
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			return child
-		}
+/** 插入新节点（注册新路由）
+ * n: 当前节点，它自身的 part 已经被消费
+ * path: pattern 中尚未消费的部分，例如 /p/:lang/doc
+ * 返回 pattern 对应的叶子节点
+ * 参数名冲突（例如 /p/:lang 与 /p/:name/doc）时 panic
+ */
+func (n *node) insert(path string, pattern string) *node {
+	if path == "" {
+		return n
 	}
-	return nil
-}
 
-// 所有匹配成功的节点，用于查找
-func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0)
-	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+	switch path[0] {
+	case ':':
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		part := path[:end]
+		_, constraint, _ := parseParam(part)
+		for _, wild := range n.wilds {
+			if wild.part == part {
+				return wild.insert(path[end:], pattern)
+			}
+			// 约束相同、名字不同的参数无法区分
+			if wild.constraint == constraint {
+				panic(fmt.Sprintf("gee: wildcard %s in %s conflicts with existing wildcard %s", part, pattern, wild.part))
+			}
+		}
+		wild := &node{part: part, typ: param, constraint: constraint}
+		if constraint == "" {
+			n.wilds = append(n.wilds, wild)
+		} else {
+			wild.match = paramMatcher(constraint, pattern)
+			// 插入到不带约束的参数之前
+			i := len(n.wilds)
+			if i > 0 && n.wilds[i-1].constraint == "" {
+				i--
+			}
+			n.wilds = append(n.wilds[:i], append([]*node{wild}, n.wilds[i:]...)...)
+		}
+		return wild.insert(path[end:], pattern)
+	case '*':
+		// 通配片段之后不会再有内容，调用方已经检查过
+		if n.catchAll == nil {
+			n.catchAll = &node{part: path, typ: catchAll}
+		} else if n.catchAll.part != path {
+			panic(fmt.Sprintf("gee: catch-all %s in %s conflicts with existing catch-all %s", path, pattern, n.catchAll.part))
 		}
+		return n.catchAll
 	}
-	return nodes
-}
 
-/** 插入新节点（注册新路由）
- * pattern: /p/:lang/doc
- * parts: [p, :lang, doc]
- * height: len(parts) -> 3
- * n: 当前层的节点，第 0 层为 '/' 节点，每次在它子节点中找 part
- */
-func (n *node) insert(pattern string, parts []string, height int) {
-	if len(parts) == height {
-		n.pattern = pattern
-		return
+	// 静态片段一直延伸到下一个参数/通配片段之前
+	end := len(path)
+	for i := 1; i < len(path); i++ {
+		if path[i-1] == '/' && (path[i] == ':' || path[i] == '*') {
+			end = i
+			break
+		}
 	}
+	lit := path[:end]
 
-	part := parts[height]
-	child := n.matchChild(part)
-	// 如果没有匹配到当前层的 part 的节点，则新建一个
-	if child == nil {
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+	for i, c := range n.indices {
+		if c != lit[0] {
+			continue
+		}
+		child := n.children[i]
+		l := commonPrefix(child.part, lit)
+		if l < len(child.part) {
+			// 公共前缀比已有节点短，需要把已有节点拆成两段
+			tail := &node{
+				part:       child.part[l:],
+				typ:        static,
+				pattern:    child.pattern,
+				indices:    child.indices,
+				children:   child.children,
+				wilds:      child.wilds,
+				catchAll:   child.catchAll,
+				paramNames: child.paramNames,
+				handlers:   child.handlers,
+				group:      child.group,
+			}
+			*child = node{
+				part:     child.part[:l],
+				typ:      static,
+				indices:  []byte{tail.part[0]},
+				children: []*node{tail},
+			}
+		}
+		return child.insert(path[l:], pattern)
 	}
-	child.insert(pattern, parts, height+1)
+
+	child := &node{part: lit, typ: static}
+	n.indices = append(n.indices, lit[0])
+	n.children = append(n.children, child)
+	return child.insert(path[end:], pattern)
 }
 
 /** 查找路由
- *  返回叶子节点
+ * n: 当前节点，它自身的 part 已经匹配
+ * path: 请求路径中尚未匹配的部分
+ * 返回叶子节点，以及按从深到浅顺序排列的参数值
+ * 静态路由不会产生任何内存分配
  */
-func (n *node) search(parts []string, height int) *node {
-	// 如果检查到当前 pattern 的最后一层，则两种情况下匹配成功
-	// 1. 当前 pattern 的最后一层是某个叶子节点
-	// 2. 当前 pattern 的最后一层对应的某层节点为通配节点
-	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+func (n *node) search(path string) (*node, []string) {
+	if path == "" {
 		if n.pattern == "" { // 说明是非叶子节点
-			return nil
+			return nil, nil
 		}
-		return n
+		return n, nil
 	}
 
-	part := parts[height]
-	children := n.matchChildren(part)
+	// 1. 静态子节点：首字节相同的子节点至多一个
+	for i, c := range n.indices {
+		if c == path[0] {
+			child := n.children[i]
+			if strings.HasPrefix(path, child.part) {
+				if leaf, values := child.search(path[len(child.part):]); leaf != nil {
+					return leaf, values
+				}
+			}
+			break
+		}
+	}
 
-	for _, child := range children {
-		result := child.search(parts, height+1)
-		// 只要有一条正确路径即可
-		if result != nil {
-			return result
+	// 2. 参数子节点：消费到下一个 '/' 为止，不满足约束的跳过
+	if len(n.wilds) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			for _, wild := range n.wilds {
+				if wild.match != nil && !wild.match(path[:end]) {
+					continue
+				}
+				if leaf, values := wild.search(path[end:]); leaf != nil {
+					return leaf, append(values, path[:end])
+				}
+			}
 		}
 	}
 
-	return nil
+	// 3. 通配子节点：消费剩余全部路径
+	if n.catchAll != nil && n.catchAll.pattern != "" {
+		return n.catchAll, []string{path}
+	}
+
+	return nil, nil
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// 以树状文本输出 n 的子节点，顺序与匹配优先级一致：静态 > 参数 > 通配
+func (n *node) dump(b *strings.Builder, indent string) {
+	children := make([]*node, 0, len(n.children)+len(n.wilds)+1)
+	children = append(children, n.children...)
+	children = append(children, n.wilds...)
+	if n.catchAll != nil {
+		children = append(children, n.catchAll)
+	}
+	for i, child := range children {
+		branch, next := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", "    "
+		}
+		b.WriteString(indent + branch + child.part)
+		if child.pattern != "" {
+			fmt.Fprintf(b, "  => %s", child.pattern)
+		}
+		b.WriteString("\n")
+		child.dump(b, indent+next)
+	}
 }
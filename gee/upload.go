@@ -0,0 +1,287 @@
+package gee
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/**
+ * 文件上传的限制，对 c.MultipartForm、c.FormFile、c.MultipartReader 和 multipart 请求的绑定都生效
+ * 超出大小限制时返回 413、文件类型不允许时返回 415 的 *HTTPError
+ */
+type UploadConfig struct {
+	// 解析 multipart 表单时保留在内存中的最大字节数，超出部分写入临时文件，默认为 32MB
+	MaxMemory int64
+	// 单个文件的最大字节数，为 0 时不限制
+	MaxFileSize int64
+	// 整个请求体的最大字节数，为 0 时不限制
+	MaxTotalSize int64
+	// 允许的文件类型，根据文件内容嗅探（http.DetectContentType）而不是客户端声明的 Content-Type，
+	// 支持 image/* 这样的通配，为空时不限制
+	AllowedTypes []string
+	// c.MultipartReader 中 Part.SaveTemp 使用的临时目录，默认为 os.TempDir()
+	// c.MultipartForm 使用 net/http 的实现，它的临时文件总是写入 os.TempDir()
+	TempDir string
+}
+
+func (cfg *UploadConfig) maxMemory() int64 {
+	if cfg.MaxMemory > 0 {
+		return cfg.MaxMemory
+	}
+	return defaultMultipartMemory
+}
+
+// 检查嗅探得到的类型是否在 AllowedTypes 中
+func (cfg *UploadConfig) allowed(filename, contentType string) error {
+	if len(cfg.AllowedTypes) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allowed := range cfg.AllowedTypes {
+		if allowed == mediaType || strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1]) {
+			return nil
+		}
+	}
+	return &HTTPError{Status: http.StatusUnsupportedMediaType, Detail: fmt.Sprintf("file %q has unsupported type %s", filename, mediaType)}
+}
+
+func (cfg *UploadConfig) tooLarge(filename string) error {
+	return &HTTPError{Status: http.StatusRequestEntityTooLarge, Detail: fmt.Sprintf("file %q exceeds the limit of %d bytes", filename, cfg.MaxFileSize)}
+}
+
+func (c *Context) uploadConfig() *UploadConfig {
+	if c.engine == nil {
+		return &UploadConfig{}
+	}
+	return &c.engine.Upload
+}
+
+// 限制请求体的总大小，多次调用只包装一次
+func (c *Context) limitBody(cfg *UploadConfig) {
+	if cfg.MaxTotalSize > 0 && c.Req.Body != nil && !c.bodyLimited {
+		c.bodyLimited = true
+		c.Req.Body = http.MaxBytesReader(c.Writer, c.Req.Body, cfg.MaxTotalSize)
+	}
+}
+
+func isMultipart(req *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == MIMEMultipartPOSTForm
+}
+
+/**
+ * 解析 multipart 表单，结果缓存在 c.Req.MultipartForm 中，重复调用直接返回
+ * 小于 MaxMemory 的部分保留在内存中，其余写入临时文件，请求结束后由 net/http 删除
+ * 解析完成后检查每个文件的大小和类型，不符合 engine.Upload 的限制时删除临时文件并返回错误
+ * 表单已经被其他代码（例如直接调用 c.Req.ParseMultipartForm）解析过时同样会检查文件，
+ * 但此时 MaxTotalSize 和 MaxMemory 已经无法生效
+ */
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.formChecked {
+		return c.Req.MultipartForm, nil
+	}
+	cfg := c.uploadConfig()
+	if c.Req.MultipartForm == nil {
+		c.limitBody(cfg)
+		if err := c.Req.ParseMultipartForm(cfg.maxMemory()); err != nil {
+			return nil, err
+		}
+	}
+	form := c.Req.MultipartForm
+	for _, headers := range form.File {
+		for _, fh := range headers {
+			if err := checkFileHeader(cfg, fh); err != nil {
+				form.RemoveAll()
+				c.Req.MultipartForm = nil
+				return nil, err
+			}
+		}
+	}
+	c.formChecked = true
+	return form, nil
+}
+
+func checkFileHeader(cfg *UploadConfig, fh *multipart.FileHeader) error {
+	if cfg.MaxFileSize > 0 && fh.Size > cfg.MaxFileSize {
+		return cfg.tooLarge(fh.Filename)
+	}
+	if len(cfg.AllowedTypes) == 0 {
+		return nil
+	}
+	contentType, err := DetectFileType(fh)
+	if err != nil {
+		return err
+	}
+	return cfg.allowed(fh.Filename, contentType)
+}
+
+// 返回表单中名为 name 的第一个文件，不存在时返回 400 的 *HTTPError，可以用 errors.Is(err, http.ErrMissingFile) 判断
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if headers := form.File[name]; len(headers) > 0 {
+		return headers[0], nil
+	}
+	return nil, &HTTPError{Status: http.StatusBadRequest, Detail: fmt.Sprintf("missing file %q", name), Err: http.ErrMissingFile}
+}
+
+// 根据文件的前 512 字节判断类型，而不是相信客户端声明的 Content-Type
+func DetectFileType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+/**
+ * 把上传的文件保存到 dst，目录不存在时自动创建
+ * fh.Filename 来自客户端，不要直接拼接到 dst 中，至少要用 filepath.Base 去掉路径
+ */
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = saveFile(src, dst)
+	return err
+}
+
+// 写入 dst，失败时删除写了一半的文件
+func saveFile(src io.Reader, dst string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return 0, err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return n, err
+}
+
+/**
+ * 以流的方式读取 multipart 请求，文件内容不经过内存缓冲或临时文件，适合大文件上传，例如
+ * mr, err := c.MultipartReader()
+ * for {
+ *     part, err := mr.NextPart()
+ *     if err == io.EOF {
+ *         break
+ *     }
+ *     if err != nil {
+ *         return err
+ *     }
+ *     if part.IsFile() {
+ *         _, err = part.SaveTo(filepath.Join(dir, filepath.Base(part.FileName())))
+ *     }
+ * }
+ * 和 c.MultipartForm 只能二选一
+ */
+func (c *Context) MultipartReader() (*MultipartReader, error) {
+	cfg := c.uploadConfig()
+	c.limitBody(cfg)
+	r, err := c.Req.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return &MultipartReader{r: r, cfg: cfg}, nil
+}
+
+type MultipartReader struct {
+	r   *multipart.Reader
+	cfg *UploadConfig
+}
+
+// 返回下一个部分，没有更多部分时返回 io.EOF
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	p, err := mr.r.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	return &Part{Part: p, cfg: mr.cfg, br: bufio.NewReaderSize(p, 512)}, nil
+}
+
+/**
+ * multipart 请求中的一个部分，读取时执行 engine.Upload 的单文件大小和类型限制
+ * 文件类型在第一次读取时根据内容嗅探，不允许的类型返回 415 的 *HTTPError
+ */
+type Part struct {
+	*multipart.Part
+	cfg     *UploadConfig
+	br      *bufio.Reader
+	n       int64
+	sniffed string
+	checked bool
+}
+
+func (p *Part) IsFile() bool {
+	return p.FileName() != ""
+}
+
+// 根据前 512 字节嗅探的内容类型，不消耗数据
+func (p *Part) ContentType() string {
+	if p.sniffed == "" {
+		head, _ := p.br.Peek(512)
+		p.sniffed = http.DetectContentType(head)
+	}
+	return p.sniffed
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	if !p.checked && p.IsFile() {
+		p.checked = true
+		if err := p.cfg.allowed(p.FileName(), p.ContentType()); err != nil {
+			return 0, err
+		}
+	}
+	n, err := p.br.Read(b)
+	p.n += int64(n)
+	if p.IsFile() && p.cfg.MaxFileSize > 0 && p.n > p.cfg.MaxFileSize {
+		return n, p.cfg.tooLarge(p.FileName())
+	}
+	return n, err
+}
+
+// 把内容写入 dst，返回写入的字节数；超出限制或类型不允许时删除 dst 并返回错误
+func (p *Part) SaveTo(dst string) (int64, error) {
+	return saveFile(p, dst)
+}
+
+// 把内容写入 engine.Upload.TempDir 中的临时文件，返回文件路径，调用者负责删除
+func (p *Part) SaveTemp() (string, int64, error) {
+	f, err := os.CreateTemp(p.cfg.TempDir, "gee-upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, p)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", n, err
+	}
+	return f.Name(), n, nil
+}
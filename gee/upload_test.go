@@ -0,0 +1,168 @@
+package gee
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// PNG 文件头，足以让 http.DetectContentType 识别为 image/png
+var pngData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func multipartRequest(t *testing.T, fields map[string]string, files map[string][]byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for name, data := range files {
+		fw, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+	}
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestFormFile(t *testing.T) {
+	dir := t.TempDir()
+	r := New()
+	r.Upload = UploadConfig{MaxFileSize: 1 << 10, AllowedTypes: []string{"image/*"}}
+	r.POST("/upload", WrapE(func(c *Context) error {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return err
+		}
+		if err := c.SaveUploadedFile(fh, filepath.Join(dir, "sub", filepath.Base(fh.Filename))); err != nil {
+			return err
+		}
+		c.String(http.StatusCreated, "%s:%s", c.PostForm("title"), fh.Filename)
+		return nil
+	}))
+
+	cases := []struct {
+		files  map[string][]byte
+		status int
+	}{
+		{map[string][]byte{"a.png": pngData}, http.StatusCreated},
+		{map[string][]byte{"a.txt": []byte("hello")}, http.StatusUnsupportedMediaType},
+		// 客户端声明的扩展名不影响嗅探结果
+		{map[string][]byte{"evil.png": []byte("<html><script>alert(1)</script>")}, http.StatusUnsupportedMediaType},
+		{map[string][]byte{"big.png": append(pngData, make([]byte, 1<<10)...)}, http.StatusRequestEntityTooLarge},
+		{nil, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, multipartRequest(t, map[string]string{"title": "cat"}, c.files))
+		if w.Code != c.status {
+			t.Fatalf("%v: expected %d, got %d %s", c.files, c.status, w.Code, w.Body.String())
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "sub", "a.png")); err != nil || !bytes.Equal(b, pngData) {
+		t.Fatalf("uploaded file was not saved: %v", err)
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	tmp := t.TempDir()
+	r := New()
+	r.Upload = UploadConfig{MaxFileSize: 200, MaxTotalSize: 1 << 20, TempDir: tmp}
+	var saved []string
+	r.POST("/upload", WrapE(func(c *Context) error {
+		mr, err := c.MultipartReader()
+		if err != nil {
+			return err
+		}
+		var fields []string
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if !part.IsFile() {
+				b, _ := io.ReadAll(part)
+				fields = append(fields, part.FormName()+"="+string(b))
+				continue
+			}
+			path, n, err := part.SaveTemp()
+			if err != nil {
+				return err
+			}
+			saved = append(saved, path)
+			fields = append(fields, part.FileName()+":"+part.ContentType())
+			if n != int64(len(pngData)) {
+				t.Fatalf("expected %d bytes, got %d", len(pngData), n)
+			}
+		}
+		c.String(http.StatusOK, "%s", strings.Join(fields, ","))
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, multipartRequest(t, map[string]string{"title": "cat"}, map[string][]byte{"a.png": pngData}))
+	if w.Code != http.StatusOK || w.Body.String() != "title=cat,a.png:image/png" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if len(saved) != 1 || filepath.Dir(saved[0]) != tmp {
+		t.Fatalf("file should be saved in the configured temp dir, got %v", saved)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, multipartRequest(t, nil, map[string][]byte{"big.png": make([]byte, 300)}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 1 {
+		t.Fatalf("partial temp file should be removed, got %d files", len(entries))
+	}
+}
+
+func TestBindMultipartLimits(t *testing.T) {
+	type form struct {
+		Title string                `form:"title"`
+		File  *multipart.FileHeader `form:"file"`
+	}
+	r := New()
+	r.Upload = UploadConfig{MaxTotalSize: 100}
+	c := r.CreateContext(httptest.NewRecorder(), multipartRequest(t, map[string]string{"title": "cat"}, map[string][]byte{"a.png": pngData}))
+	var f form
+	if err := c.ShouldBind(&f); !isBodyTooLarge(err) {
+		t.Fatalf("expected MaxBytesError, got %v", err)
+	}
+}
+
+func TestMultipartFormParsedElsewhere(t *testing.T) {
+	r := New()
+	r.Upload = UploadConfig{MaxFileSize: 10}
+	// 前面的中间件直接用 net/http 解析了表单，例如读取表单中的 CSRF 令牌
+	r.Use(func(c *Context) {
+		c.Req.ParseMultipartForm(32 << 20)
+		c.Next()
+	})
+	r.POST("/upload", WrapE(func(c *Context) error {
+		if _, err := c.FormFile("file"); err != nil {
+			return err
+		}
+		c.Status(http.StatusOK)
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, multipartRequest(t, nil, map[string][]byte{"big.png": make([]byte, 1000)}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,261 @@
+package gee
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 单个字段的校验错误，可以直接交给 c.JSON 渲染
+type FieldError struct {
+	Field   string `json:"field"`           // 字段路径，例如 address.city、items[0].name
+	Rule    string `json:"rule"`            // 未通过的规则，例如 required、min
+	Param   string `json:"param,omitempty"` // 规则参数，例如 min=3 中的 3
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Message
+}
+
+// 一次校验中收集到的全部错误
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+/**
+ * 根据 binding tag 校验结构体，例如
+ * type Login struct {
+ *     User     string `json:"user" binding:"required,min=3,max=16"`
+ *     Role     string `json:"role" binding:"oneof=admin guest"`
+ *     Code     string `json:"code" binding:"len=6,regex=^[0-9]+$"`
+ * }
+ *
+ * 支持的规则：
+ *   required  值不能为零值（切片、map 不能为空，指针不能为 nil）
+ *   min/max   数字比较大小，字符串、切片、map 比较长度
+ *   len       字符串、切片、map 的长度必须相等
+ *   oneof     值必须是空格分隔的候选值之一
+ *   regex     字符串必须匹配正则，regex 必须是最后一条规则，其后的逗号都属于正则本身
+ *
+ * 指针为 nil 时只检查 required，嵌套的结构体和结构体切片会被递归校验
+ * 全部字段都会被检查，返回的 ValidationErrors 包含所有未通过的规则
+ */
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs ValidationErrors
+	validateStruct(v, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // 未导出的字段
+			continue
+		}
+		fv := v.Field(i)
+		name := prefix + fieldName(field)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			validateStruct(fv, prefix, errs)
+			continue
+		}
+
+		if tag := field.Tag.Get("binding"); tag != "" && tag != "-" {
+			for _, rule := range parseRules(tag) {
+				if e, ok := checkRule(fv, name, rule); !ok {
+					*errs = append(*errs, e)
+				}
+			}
+		}
+
+		// 递归校验嵌套的结构体
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && fv.Type() != timeType:
+			validateStruct(fv, name+".", errs)
+		case fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array:
+			for j := 0; j < fv.Len(); j++ {
+				elem := fv.Index(j)
+				for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+					elem = elem.Elem()
+				}
+				if elem.Kind() == reflect.Struct && elem.Type() != timeType {
+					validateStruct(elem, fmt.Sprintf("%s[%d].", name, j), errs)
+				}
+			}
+		}
+	}
+}
+
+// 校验错误中使用客户端看到的字段名：依次取 json、form、xml tag，都没有时使用字段名
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "xml"} {
+		if name := strings.Split(field.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+// 按逗号切分规则，regex 之后的内容整体作为正则
+func parseRules(tag string) []rule {
+	rules := make([]rule, 0)
+	for tag != "" {
+		var item string
+		if strings.HasPrefix(tag, "regex=") {
+			item, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			item, tag = tag[:i], tag[i+1:]
+		} else {
+			item, tag = tag, ""
+		}
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		r := rule{name: item}
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			r.name, r.param = item[:i], item[i+1:]
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+var regexCache sync.Map // 正则表达式 -> *regexp.Regexp
+
+func compileRegex(expr string) *regexp.Regexp {
+	if re, ok := regexCache.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	// 正则写在 tag 里，写错属于编程错误
+	re := regexp.MustCompile(expr)
+	regexCache.Store(expr, re)
+	return re
+}
+
+func checkRule(v reflect.Value, name string, r rule) (FieldError, bool) {
+	fail := func(format string, args ...interface{}) (FieldError, bool) {
+		return FieldError{
+			Field:   name,
+			Rule:    r.name,
+			Param:   r.param,
+			Message: fmt.Sprintf("%s "+format, append([]interface{}{name}, args...)...),
+		}, false
+	}
+
+	if r.name == "required" {
+		if isZero(v) {
+			return fail("is required")
+		}
+		return FieldError{}, true
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return FieldError{}, true
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "min", "max", "len":
+		bound, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("gee: invalid %s=%s on field %s", r.name, r.param, name))
+		}
+		value, isLength, ok := measure(v)
+		if !ok {
+			panic(fmt.Sprintf("gee: rule %s is not applicable to field %s of kind %s", r.name, name, v.Kind()))
+		}
+		unit := ""
+		if isLength {
+			unit = " in length"
+		}
+		switch {
+		case r.name == "min" && value < bound:
+			return fail("must be at least %s%s", r.param, unit)
+		case r.name == "max" && value > bound:
+			return fail("must be at most %s%s", r.param, unit)
+		case r.name == "len" && value != bound:
+			return fail("must be exactly %s%s", r.param, unit)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, candidate := range strings.Fields(r.param) {
+			if s == candidate {
+				return FieldError{}, true
+			}
+		}
+		return fail("must be one of [%s]", r.param)
+	case "regex":
+		if v.Kind() != reflect.String {
+			panic(fmt.Sprintf("gee: rule regex is not applicable to field %s of kind %s", name, v.Kind()))
+		}
+		if !compileRegex(r.param).MatchString(v.String()) {
+			return fail("must match %s", r.param)
+		}
+	default:
+		panic(fmt.Sprintf("gee: unknown binding rule %q on field %s", r.name, name))
+	}
+	return FieldError{}, true
+}
+
+// 数字返回值本身，字符串（按字符数）、切片、map 返回长度
+func measure(v reflect.Value) (value float64, isLength bool, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true, true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
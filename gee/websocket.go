@@ -0,0 +1,95 @@
+package gee
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 默认的单条消息大小上限
+const defaultWSReadLimit = 32 << 20
+
+/**
+ * WebSocket 握手的配置，零值即可使用，协议本身由 gorilla/websocket 实现
+ * CheckOrigin 为 nil 时要求 Origin 与 Host 一致（没有 Origin 头的非浏览器客户端直接放行）
+ * Subprotocols 为服务端支持的子协议，按服务端的优先级排列
+ * ReadLimit 为单条消息的大小上限，为 0 时使用 defaultWSReadLimit
+ */
+type Upgrader struct {
+	CheckOrigin  func(req *http.Request) bool
+	Subprotocols []string
+	ReadLimit    int64
+}
+
+/**
+ * 在 gee 的中间件链内完成 WebSocket 握手，之前的鉴权、日志等中间件都已经执行过
+ * 握手失败时已经写好了错误响应（400/403/405 等），调用者直接返回即可
+ * 握手成功后连接被接管，不能再使用 c.Writer 写响应
+ */
+func (c *Context) Upgrade() (*websocket.Conn, error) {
+	var u Upgrader
+	if c.engine != nil {
+		u = c.engine.Upgrader
+	}
+	return u.upgrade(c)
+}
+
+func (u *Upgrader) upgrade(c *Context) (*websocket.Conn, error) {
+	upgrader := websocket.Upgrader{
+		CheckOrigin:  u.CheckOrigin,
+		Subprotocols: u.Subprotocols,
+		Error: func(w http.ResponseWriter, req *http.Request, code int, reason error) {
+			c.AbortWithStatusJSON(code, H{"message": reason.Error()})
+		},
+	}
+	// 先记录 101，这样 Logger 等中间件能看到正确的状态码；握手失败时会被错误响应覆盖
+	c.Status(http.StatusSwitchingProtocols)
+	conn, err := upgrader.Upgrade(c.Writer, c.Req, nil)
+	if err != nil {
+		return nil, err
+	}
+	// 清除 http.Server 按 ReadTimeout、WriteTimeout 设置的超时，较早的 Go 版本在 Hijack 时不会清除，
+	// 连接会在超时之后被断开
+	// 需要超时的话由 handler 通过 SetReadDeadline、SetWriteDeadline 自行设置
+	if err := conn.UnderlyingConn().SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	readLimit := u.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWSReadLimit
+	}
+	conn.SetReadLimit(readLimit)
+	return conn, nil
+}
+
+// WebSocket 连接的处理函数，返回时连接会被关闭
+type WSHandlerFunc func(c *Context, conn *websocket.Conn)
+
+/**
+ * 注册 WebSocket 路由，分组上的中间件会在握手之前执行
+ * handler 返回后以 1000 关闭连接；handler panic 时以 1011 关闭连接，
+ * 然后继续向上 panic，交给 Recovery 记录
+ */
+func (group *RouterGroup) WS(pattern string, handler WSHandlerFunc) *Route {
+	return group.GET(pattern, func(c *Context) {
+		conn, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		defer func() {
+			err := recover()
+			code, text := websocket.CloseNormalClosure, ""
+			if err != nil {
+				code, text = websocket.CloseInternalServerErr, "internal server error"
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+			conn.Close()
+			if err != nil {
+				panic(err)
+			}
+		}()
+		handler(c, conn)
+	})
+}
@@ -0,0 +1,112 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialWS(t *testing.T, server *httptest.Server, path string) *websocket.Conn {
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expect 101, got %d", resp.StatusCode)
+	}
+	return conn
+}
+
+func echo(c *Context, conn *websocket.Conn) {
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(mt, data)
+	}
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	r := New()
+	r.Use(Recovery())
+	var authed bool
+	ws := r.Group("/ws")
+	ws.Use(func(c *Context) {
+		authed = true
+		c.Next()
+	})
+	ws.WS("/echo", echo)
+	ws.WS("/panic", func(c *Context, conn *websocket.Conn) {
+		panic("boom")
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	client := dialWS(t, server, "/ws/echo")
+	if !authed {
+		t.Fatal("group middleware should run before upgrade")
+	}
+	client.WriteMessage(websocket.TextMessage, []byte("hello"))
+	mt, data, err := client.ReadMessage()
+	if err != nil || mt != websocket.TextMessage || string(data) != "hello" {
+		t.Fatalf("unexpected echo %d %q %v", mt, data, err)
+	}
+	client.Close()
+
+	client = dialWS(t, server, "/ws/panic")
+	_, _, err = client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseInternalServerErr) {
+		t.Fatalf("expect close 1011, got %v", err)
+	}
+}
+
+func TestWebSocketServerTimeouts(t *testing.T) {
+	r := New()
+	r.WS("/echo", echo)
+	server := httptest.NewUnstartedServer(r)
+	server.Config.ReadTimeout = 50 * time.Millisecond
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	client := dialWS(t, server, "/echo")
+	// 超过 http.Server 的超时之后连接仍然可用
+	time.Sleep(100 * time.Millisecond)
+	client.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if _, data, err := client.ReadMessage(); err != nil || string(data) != "hello" {
+		t.Fatalf("connection should outlive the server timeouts, got %q %v", data, err)
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	r := New()
+	r.WS("/ws", func(c *Context, conn *websocket.Conn) {})
+	cases := []struct {
+		origin string
+		header bool
+		code   int
+	}{
+		{"", false, http.StatusBadRequest},
+		{"http://evil.example", true, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		if c.header {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			req.Header.Set("Origin", c.origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Fatalf("expect %d, got %d", c.code, w.Code)
+		}
+	}
+}